@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+)
+
+// exportCache keeps CUE exports of unchanged sources, nil with --no-cache.
+var exportCache = &source.ExportCache{Dir: source.ExportCacheDir()}
+
+type CacheClearCmd struct{}
+
+type CacheCmd struct {
+	Clear *CacheClearCmd `arg:"subcommand:clear" help:"remove every cached CUE export"`
+}
+
+func (c *CacheCmd) runCache() error {
+	switch {
+	case c.Clear != nil:
+		return (&source.ExportCache{Dir: source.ExportCacheDir()}).Clear()
+	default:
+		return fmt.Errorf("Missing cache command, use iogo cache clear")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/redact"
+	"github.com/pkg/errors"
+)
+
+// projectConfig is read from --config, .iogo.json in the project by default,
+// like:
+//
+//	{
+//	  "redact": {"allow": ["KEYBOARD_LAYOUT"], "deny": ["DATABASE_URL"]},
+//	  "cue": {"dir": "jobs", "package": "bitte", "tags": {"env": "dev"}}
+//	}
+type projectConfig struct {
+	Redact redact.Rules `json:"redact"`
+	// Source is cue, nix or json.
+	Source string     `json:"source,omitempty"`
+	Cue    cueConfig  `json:"cue"`
+	Nix    nixConfig  `json:"nix"`
+	Json   jsonConfig `json:"json"`
+}
+
+// cueConfig says where and how to export jobs from CUE. The flags and their
+// environment variables take precedence over it.
+type cueConfig struct {
+	// Dir is relative to the config file.
+	Dir        string            `json:"dir,omitempty"`
+	Package    string            `json:"package,omitempty"`
+	Expression string            `json:"expression,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+type nixConfig struct {
+	Attribute string `json:"attribute,omitempty"`
+}
+
+type jsonConfig struct {
+	// Dir is relative to the config file.
+	Dir string `json:"dir,omitempty"`
+}
+
+// loadConfig reads the config at path, which doesn't have to exist.
+func loadConfig(path string) (*projectConfig, error) {
+	config := &projectConfig{}
+
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, errors.WithMessagef(err, "Reading %s", path)
+	}
+
+	for _, dir := range []*string{&config.Cue.Dir, &config.Json.Dir} {
+		if *dir != "" && !filepath.IsAbs(*dir) {
+			*dir = filepath.Join(filepath.Dir(path), *dir)
+		}
+	}
+
+	return config, nil
+}
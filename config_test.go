@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/redact"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "iogo")
+	r.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".iogo.json")
+
+	config, err := loadConfig(path)
+	r.Nil(err)
+	r.Equal(&projectConfig{}, config)
+
+	r.Nil(ioutil.WriteFile(path, []byte(`{"redact": {"allow": ["KEYBOARD_LAYOUT"], "deny": ["DATABASE_URL"]}}`), 0644))
+	config, err = loadConfig(path)
+	r.Nil(err)
+	r.Equal(redact.Rules{Allow: []string{"KEYBOARD_LAYOUT"}, Deny: []string{"DATABASE_URL"}}, config.Redact)
+
+	r.Nil(ioutil.WriteFile(path, []byte(`{"cue": {"dir": "jobs", "package": "bitte", "tags": {"env": "dev"}}}`), 0644))
+	config, err = loadConfig(path)
+	r.Nil(err)
+	r.Equal(cueConfig{Dir: filepath.Join(dir, "jobs"), Package: "bitte", Tags: map[string]string{"env": "dev"}}, config.Cue)
+
+	r.Nil(ioutil.WriteFile(path, []byte(`{"redact": []}`), 0644))
+	_, err = loadConfig(path)
+	r.Contains(err.Error(), "Reading "+path)
+}
+
+func TestCueSettingsFor(t *testing.T) {
+	r := require.New(t)
+
+	config := cueConfig{Dir: "jobs", Package: "bitte", Tags: map[string]string{"env": "dev", "region": "eu"}}
+
+	c, err := cueSettingsFor(&iogo{}, config)
+	r.Nil(err)
+	r.Equal(source.Cue{Dir: "jobs", Package: "bitte", Tags: []string{"env=dev", "region=eu"}}, c)
+
+	c, err = cueSettingsFor(&iogo{CueDir: "other", Expression: "jobs.prod", Tags: []string{"env=prod", "team=ops"}}, config)
+	r.Nil(err)
+	r.Equal(source.Cue{
+		Dir:        "other",
+		Package:    "bitte",
+		Expression: "jobs.prod",
+		Tags:       []string{"env=prod", "region=eu", "team=ops"},
+	}, c)
+
+	_, err = cueSettingsFor(&iogo{Tags: []string{"env"}}, config)
+	r.EqualError(err, `Invalid --tag "env", use KEY=VALUE`)
+}
@@ -1,70 +1,86 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"sort"
+	"strings"
 
-	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
 )
 
-func cueExport() (*CueExport, error) {
-	cueVet()
+// cueSettings are --cue-dir, --cue-package, --expression and --tag, or
+// their defaults from the project config.
+var cueSettings = source.Cue{}
 
-	cmd := exec.Command(cue, "export")
-	output, err := cmd.CombinedOutput()
+func cueSource() *source.Cue {
+	c := cueSettings
+	c.Command = cue
+	c.Cache = exportCache
 
-	if err != nil {
-		if len(output) > 0 {
-			fmt.Printf("%s\n", output)
-		}
+	return &c
+}
 
-		panic(err)
+// cueDir is where the CUE sources are.
+func cueDir() string {
+	if cueSettings.Dir == "" {
+		return "."
 	}
 
-	export := &CueExport{}
-	err = json.Unmarshal(output, export)
-
-	return export, err
+	return cueSettings.Dir
 }
 
-type JobWrapper struct {
-	Job *api.Job
-}
+// cueSettingsFor combines the flags with the project config, the flags win.
+// Tags given both ways are merged by key.
+func cueSettingsFor(args *iogo, config cueConfig) (source.Cue, error) {
+	c := source.Cue{
+		Dir:        firstOf(args.CueDir, config.Dir),
+		Package:    firstOf(args.CuePackage, config.Package),
+		Expression: firstOf(args.Expression, config.Expression),
+	}
 
-type CueExport struct {
-	Rendered map[string]map[string]JobWrapper
-}
+	tags := map[string]string{}
+	for key, value := range config.Tags {
+		tags[key] = value
+	}
 
-func cue2hcl(namespace, job string) (*hclwrite.File, error) {
-	cueVet()
+	for _, tag := range args.Tags {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return c, fmt.Errorf("Invalid --tag %q, use KEY=VALUE", tag)
+		}
+		tags[parts[0]] = parts[1]
+	}
 
-	export, err := cueExport()
-	if err != nil {
-		return nil, err
+	for key, value := range tags {
+		c.Tags = append(c.Tags, key+"="+value)
 	}
+	sort.Strings(c.Tags)
+
+	return c, nil
+}
 
-	if foundNamespace, ok := export.Rendered[namespace]; ok {
-		if foundJob, ok := foundNamespace[job]; ok {
-			return any2hcl("job", foundJob.Job)
-		} else {
-			return nil, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
 		}
 	}
 
-	return nil, fmt.Errorf("Missing namespace %s", namespace)
+	return ""
 }
 
-func cueVet() {
-	cmd := exec.Command(cue, "vet", "-c", "./...")
-	output, err := cmd.CombinedOutput()
-
-	if len(output) > 0 {
-		fmt.Printf("%s\n", output)
+// cueFailed reports the errors of a failed cue command as diagnostics, with
+// the file and line they point to, instead of dumping its output.
+func cueFailed(err error) error {
+	var cueErr *source.CueError
+	if !errors.As(err, &cueErr) || len(cueErr.Diagnostics) == 0 {
+		return err
 	}
 
-	if err != nil {
-		panic(err)
+	if err := diagnostics.Write(cueErr.Diagnostics); err != nil {
+		return err
 	}
+
+	return fmt.Errorf("%s failed with %d errors", cueErr.Command, len(cueErr.Diagnostics))
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+)
+
+type ExportCmd struct {
+	Output string `arg:"-o" help:"write the export to this file (- for stdout)" placeholder:"FILE"`
+}
+
+// runExport saves all jobs from CUE, for --from-export on machines without
+// cue.
+func runExport(args *ExportCmd) error {
+	saved, diags, err := source.SaveExport(context.Background(), cueSource(), Version())
+	if err := diagnostics.Write(diags); err != nil {
+		return err
+	}
+	if err != nil {
+		return cueFailed(err)
+	}
+
+	redactor.Deny(saved.Secrets...)
+
+	for _, jobs := range saved.Rendered {
+		for name, wrapper := range jobs {
+			if wrapper.Job, err = redactOutput(args.Output, wrapper.Job); err != nil {
+				return err
+			}
+			jobs[name] = wrapper
+		}
+	}
+
+	output, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput(args.Output)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "%s\n", output)
+	return err
+}
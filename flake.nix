@@ -30,15 +30,30 @@
           meta.description = "one CLI for bitte nomad jobs";
 
           src = inputs.inclusive.lib.inclusive ./. [
+            ./cache.go
+            ./config.go
+            ./config_test.go
             ./cue.go
-            ./fixtures
+            ./export.go
+            ./format.go
+            ./format_test.go
             ./go.mod
             ./go.sum
+            ./hcl2json.go
             ./job.hcl
             ./json2hcl.go
             ./json2hcl_test.go
+            ./lint.go
             ./login.go
             ./main.go
+            ./nomad.go
+            ./nomad_test.go
+            ./pkg
+            ./provenance.go
+            ./provenance_test.go
+            ./redact.go
+            ./source.go
+            ./source_test.go
           ];
 
           ldflags = [
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+	"github.com/pkg/errors"
+)
+
+// Output formats of render and json2hcl.
+const (
+	formatHcl2 = "hcl2"
+	formatHcl1 = "hcl1"
+	// formatJson is {"Job": ...}, like hcl2json writes it.
+	formatJson = "json"
+	// formatApiJson is the body of PUT /v1/jobs.
+	formatApiJson = "api-json"
+)
+
+// jobRenderer writes jobs in one of the output formats, checking them with
+// --verify and --coverage on the way.
+type jobRenderer struct {
+	// Format is one of the output formats, hcl2 if empty.
+	Format string
+	// Canonicalize fills in the defaults Nomad would, for the JSON formats.
+	Canonicalize bool
+	Verify       bool
+	Coverage     bool
+	Strict       bool
+	Options      hcl.Options
+}
+
+func (r *jobRenderer) check() error {
+	switch r.Format {
+	case "", formatHcl2, formatHcl1:
+		if r.Canonicalize {
+			return fmt.Errorf("--canonicalize only works with --format %s or %s", formatJson, formatApiJson)
+		}
+	case formatJson, formatApiJson:
+		if flag := r.hclFlag(); flag != "" {
+			return fmt.Errorf("%s only works with --format %s or %s", flag, formatHcl2, formatHcl1)
+		}
+	default:
+		return fmt.Errorf("Unknown format %q, use one of %s, %s, %s or %s", r.Format, formatHcl2, formatHcl1, formatJson, formatApiJson)
+	}
+
+	if (r.Verify || r.Coverage) && r.Format != "" && r.Format != formatHcl2 {
+		return fmt.Errorf("--verify and --coverage only work with --format %s", formatHcl2)
+	}
+
+	return nil
+}
+
+// hclFlag names the first flag given that only changes how HCL is written,
+// which the JSON formats would ignore.
+func (r *jobRenderer) hclFlag() string {
+	opts := r.Options
+
+	switch {
+	case opts.Heredoc != "" && opts.Heredoc != hcl.HeredocIndent:
+		return "--heredoc"
+	case opts.ExtractVars:
+		return "--extract-vars"
+	case len(opts.Variables) > 0:
+		return "--extract-var"
+	case opts.Annotate != nil:
+		return "--annotate"
+	case opts.NomadVersion != "":
+		return "--nomad-version"
+	}
+
+	return ""
+}
+
+// isHcl is true for the formats that can have a provenance header.
+func (r *jobRenderer) isHcl() bool {
+	return r.Format == "" || r.Format == formatHcl2 || r.Format == formatHcl1
+}
+
+// extension of files in this format.
+func (r *jobRenderer) extension() string {
+	if r.isHcl() {
+		return ".hcl"
+	}
+
+	return ".json"
+}
+
+// render converts job and reports its diagnostics. The JSON formats
+// canonicalize job in place.
+func (r *jobRenderer) render(job *api.Job) ([]byte, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	switch r.Format {
+	case formatJson, formatApiJson:
+		if r.Canonicalize {
+			job.Canonicalize()
+		}
+
+		var body interface{} = &source.JobWrapper{Job: job}
+		if r.Format == formatApiJson {
+			body = &api.JobRegisterRequest{Job: job}
+		}
+
+		output, err := json.MarshalIndent(body, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return append(output, '\n'), nil
+	}
+
+	opts := r.Options
+	opts.Syntax = r.Format
+
+	file, diags, err := hcl.Render(job, opts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Trying to transform Job to HCL")
+	}
+
+	// the fields left out for --nomad-version are already warned about
+	rendered, err := hcl.FilterVersion(job, opts.NomadVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Coverage {
+		missing, err := hcl.Coverage(rendered, file)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, missing...)
+	}
+
+	// report first, the warnings may explain why verifying fails
+	if err := diagnostics.Report(diags, r.Strict); err != nil {
+		return nil, err
+	}
+
+	if r.Verify {
+		if err := hcl.Verify(rendered, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return file.Bytes(), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRendererFormats(t *testing.T) {
+	r := require.New(t)
+
+	job := func() *api.Job {
+		return &api.Job{ID: ptrStr("web"), Name: ptrStr("web"), Datacenters: []string{"dc1"}}
+	}
+
+	src, err := (&jobRenderer{Format: formatHcl1}).render(job())
+	r.Nil(err)
+	r.Equal("job \"web\" {\n  id          = \"web\"\n  datacenters = [\"dc1\"]\n}\n", string(src))
+
+	src, err = (&jobRenderer{Format: formatJson}).render(job())
+	r.Nil(err)
+	r.Contains(string(src), "{\n  \"Job\": {\n")
+
+	src, err = (&jobRenderer{Format: formatApiJson, Canonicalize: true}).render(job())
+	r.Nil(err)
+	request := &api.JobRegisterRequest{}
+	r.Nil(json.Unmarshal(src, request))
+	r.Equal("web", *request.Job.Name)
+	r.Equal("service", *request.Job.Type)
+	r.Equal("default", *request.Job.Namespace)
+
+	_, err = (&jobRenderer{Format: formatHcl2, Canonicalize: true}).render(job())
+	r.EqualError(err, "--canonicalize only works with --format json or api-json")
+
+	_, err = (&jobRenderer{Format: formatApiJson, Verify: true}).render(job())
+	r.EqualError(err, "--verify and --coverage only work with --format hcl2")
+
+	for flag, opts := range map[string]hcl.Options{
+		"--heredoc":       {Heredoc: hcl.HeredocNever},
+		"--extract-vars":  {ExtractVars: true},
+		"--extract-var":   {Variables: map[string]string{"dc": "dc1"}},
+		"--annotate":      {Annotate: func(string) string { return "" }},
+		"--nomad-version": {NomadVersion: "1.0.4"},
+	} {
+		_, err = (&jobRenderer{Format: formatJson, Options: opts}).render(job())
+		r.EqualError(err, flag+" only works with --format hcl2 or hcl1")
+	}
+
+	// the default heredoc mode is fine
+	_, err = (&jobRenderer{Format: formatApiJson, Options: hcl.Options{Heredoc: hcl.HeredocIndent}}).render(job())
+	r.Nil(err)
+}
+
+func TestJobRendererVerifyVersion(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("web"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("web"),
+			Tasks: []*api.Task{{
+				Name:      "server",
+				Driver:    "exec",
+				Resources: &api.Resources{MemoryMB: ptrInt(100), MemoryMaxMB: ptrInt(200)},
+			}},
+		}},
+	}
+
+	// memory_max is left out on purpose, which verify doesn't count
+	src, err := (&jobRenderer{Verify: true, Coverage: true, Options: hcl.Options{NomadVersion: "1.0.4"}}).render(job)
+	r.Nil(err)
+	r.NotContains(string(src), "memory_max")
+}
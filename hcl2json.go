@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+	"github.com/pkg/errors"
+)
+
+type Hcl2JsonCmd struct {
+	Output string `arg:"-o" help:"write output to this file (- for stdout)" placeholder:"FILE"`
+	Input  string `arg:"-i" help:"read HCL from this file (- for stdin)" placeholder:"FILE"`
+}
+
+func runHcl2Json(args *Hcl2JsonCmd) error {
+	read, err := openInput(args.Input)
+	if err != nil {
+		return err
+	}
+
+	input, err := io.ReadAll(read)
+	if err != nil {
+		return err
+	}
+
+	filename := args.Input
+	if isStdpipe(filename) {
+		filename = "<stdin>"
+	}
+
+	job, err := hcl.Parse(input, filename)
+	if err != nil {
+		return errors.WithMessage(err, "Trying to transform HCL to Job")
+	}
+
+	job, err = redactOutput(args.Output, job)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(&source.JobWrapper{Job: job}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	write, err := openOutput(args.Output)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(write, "%s\n", output)
+	return err
+}
@@ -1,24 +1,31 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
-	"reflect"
-	"sort"
-	"strings"
-	"time"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
-	"github.com/hashicorp/hcl/v2/hclsyntax"
-	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
 	"github.com/pkg/errors"
-	"github.com/zclconf/go-cty/cty"
 )
 
 type Json2HclCmd struct {
-	Output string `arg:"-o" help:"write output to this file (- for stdout)" placeholder:"FILE"`
-	Input  string `arg:"-i" help:"read JSON from this file (- for stdin)" placeholder:"FILE"`
+	Output       string   `arg:"-o" help:"write output to this file (- for stdout), or the index with --output-dir" placeholder:"FILE"`
+	Input        string   `arg:"-i" help:"read JSON from this file (- for stdin)" placeholder:"FILE"`
+	OutputDir    string   `arg:"--output-dir" help:"write every job of the input to DIR/<namespace>/<job>.hcl, or .json" placeholder:"DIR"`
+	Format       string   `arg:"--format" default:"hcl2" help:"write hcl2, hcl1, json or api-json (the body of PUT /v1/jobs)"`
+	Canonicalize bool     `arg:"--canonicalize" help:"fill in Nomad's defaults, with --format json or api-json"`
+	Verify       bool     `arg:"--verify" help:"parse the generated HCL again and fail if it differs from the input"`
+	Heredoc      string   `arg:"--heredoc" default:"indent" help:"how to write multi-line strings: indent, flat or never"`
+	Coverage     bool     `arg:"--coverage" help:"list fields of the input that are missing from the HCL"`
+	Strict       bool     `arg:"--strict" help:"fail on warnings from the converter or --coverage"`
+	ExtractVars  bool     `arg:"--extract-vars" help:"lift values that appear more than once into variable blocks"`
+	ExtractVar   []string `arg:"--extract-var,separate" help:"lift VALUE into a variable block called NAME" placeholder:"NAME=VALUE"`
+	NomadVersion string   `arg:"--nomad-version,env:IOGO_NOMAD_VERSION" help:"leave out fields this Nomad version doesn't know, auto asks the agent" placeholder:"VERSION"`
 }
 
 func runJson2Hcl(args *Json2HclCmd) error {
@@ -32,16 +39,27 @@ func runJson2Hcl(args *Json2HclCmd) error {
 		return err
 	}
 
-	wrapper := &JobWrapper{}
+	jobs, err := source.ReadJobs(input)
+	if err != nil {
+		return err
+	}
+
+	if args.OutputDir != "" {
+		return args.writeJobs(jobs)
+	}
 
-	err = json.Unmarshal(input, wrapper)
+	if len(jobs) != 1 {
+		return fmt.Errorf("Input has %d jobs, use --output-dir to write all of them", len(jobs))
+	}
+
+	job, err := redactOutput(args.Output, jobs[0].Job)
 	if err != nil {
 		return err
 	}
 
-	file, err := any2hcl("job", wrapper.Job)
+	src, err := args.convert(job)
 	if err != nil {
-		return errors.WithMessage(err, "Trying to transform Job to HCL")
+		return err
 	}
 
 	write, err := openOutput(args.Output)
@@ -49,423 +67,81 @@ func runJson2Hcl(args *Json2HclCmd) error {
 		return err
 	}
 
-	_, err = file.WriteTo(write)
+	_, err = write.Write(src)
 	return err
 }
 
-func any2hcl(key string, any interface{}) (*hclwrite.File, error) {
-	f := hclwrite.NewEmptyFile()
-	body := f.Body()
+func (args *Json2HclCmd) renderer() (*jobRenderer, error) {
+	vars, err := parseVariables(args.ExtractVar)
+	if err != nil {
+		return nil, err
+	}
 
-	rv := reflect.ValueOf(any)
-	if rv.Kind() == reflect.Ptr {
-		return f, convert(body, key, rv.Elem().Interface())
-	} else {
-		return f, convert(body, key, any)
+	version, err := nomadVersion(args.NomadVersion)
+	if err != nil {
+		return nil, err
 	}
-}
 
-type structField struct {
-	Name     string
-	Block    bool
-	Optional bool
-	Label    bool
-	Value    interface{}
+	return &jobRenderer{
+		Format:       args.Format,
+		Canonicalize: args.Canonicalize,
+		Verify:       args.Verify,
+		Coverage:     args.Coverage,
+		Strict:       args.Strict,
+		Options: hcl.Options{
+			Heredoc:      args.Heredoc,
+			ExtractVars:  args.ExtractVars,
+			Variables:    vars,
+			NomadVersion: version,
+		},
+	}, nil
 }
 
-func (s *structField) Cty() cty.Value {
-	switch v := s.Value.(type) {
-	case nil:
-		return cty.NilVal
-	case string:
-		if v == "" && s.Optional {
-			return cty.NilVal
-		} else {
-			return cty.StringVal(v)
-		}
-	case *string:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.StringVal(*v)
-		}
-	case int8:
-		return cty.NumberIntVal(int64(v))
-	case *int8:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.NumberIntVal(int64(*v))
-		}
-	case uint64:
-		return cty.NumberIntVal(int64(v))
-	case *uint64:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.NumberIntVal(int64(*v))
-		}
-	case int:
-		if v == 0 && s.Optional {
-			return cty.NilVal
-		} else {
-			return cty.NumberIntVal(int64(v))
-		}
-	case *int:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.NumberIntVal(int64(*v))
-		}
-	case bool:
-		if !v && s.Optional {
-			return cty.NilVal
-		} else {
-			return cty.BoolVal(v)
-		}
-	case *bool:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.BoolVal(*v)
-		}
-	case time.Duration:
-		if v == 0 && s.Optional {
-			return cty.NilVal
-		} else {
-			return cty.StringVal(v.String())
-		}
-	case *time.Duration:
-		if v == nil {
-			return cty.NilVal
-		} else {
-			return cty.StringVal(v.String())
-		}
-	case []string:
-		if len(v) == 0 {
-			if s.Optional {
-				return cty.NilVal
-			} else {
-				return cty.ListValEmpty(cty.String)
-			}
-		} else {
-			converted := []cty.Value{}
-			for _, value := range v {
-				converted = append(converted, cty.StringVal(value))
-			}
-			return cty.ListVal(converted)
-		}
-	default:
-		panic(fmt.Sprintf("Unknown type for: %t, %#t %#v", v, v, v))
+// convert turns one job into the output format and reports its
+// diagnostics.
+func (args *Json2HclCmd) convert(job *api.Job) ([]byte, error) {
+	r, err := args.renderer()
+	if err != nil {
+		return nil, err
 	}
-}
 
-func convert(parent *hclwrite.Body, key string, obj interface{}) error {
-	objType := reflect.TypeOf(obj)
-	objValue := reflect.ValueOf(obj)
+	return r.render(job)
+}
 
-	if objValue.Kind() == reflect.Ptr {
-		objValue = objValue.Elem()
+// writeJobs writes each job to its own file below args.OutputDir and an
+// index of those files to args.Output.
+func (args *Json2HclCmd) writeJobs(jobs []*source.NamespacedJob) error {
+	index, err := openOutput(args.Output)
+	if err != nil {
+		return err
 	}
 
-	// skip everything without value
-	switch objValue.Kind() {
-	case reflect.Invalid:
-		return nil
-	case reflect.Struct:
-		if obj == nil {
-			return nil
-		}
-	case reflect.Array, reflect.Slice, reflect.Map:
-		if objValue.Len() == 0 {
-			return nil
-		}
-	default:
-		fmt.Println("objType.Kind:", objType.Kind(), "objValue.kind:", objValue.Kind())
+	r, err := args.renderer()
+	if err != nil {
+		return err
 	}
 
-	switch objValue.Kind() {
-	case reflect.Map:
-		if len(parent.Attributes()) > 0 || len(parent.Blocks()) > 0 {
-			parent.AppendNewline()
+	for _, job := range jobs {
+		path, err := job.Path(args.OutputDir, r.extension())
+		if err != nil {
+			return err
 		}
 
-		switch t := obj.(type) {
-		case map[string]*api.VolumeRequest:
-			for mapKey, mapValue := range t {
-				body := parent.AppendNewBlock(key, []string{mapKey}).Body()
-				if err := convert(body, "", *mapValue); err != nil {
-					return err
-				}
-			}
-		case map[string]*api.ConsulGatewayBindAddress:
-			for mapKey, mapValue := range t {
-				body := parent.AppendNewBlock(key, []string{mapKey}).Body()
-				if err := convert(body, "", *mapValue); err != nil {
-					return err
-				}
-			}
-		case map[string]string, map[string]interface{}:
-			body := parent.AppendNewBlock(key, []string{}).Body()
-
-			keyValues := objValue.MapKeys()
-			keys := []string{}
-			for _, keyValue := range keyValues {
-				keys = append(keys, keyValue.String())
-			}
-
-			sort.Strings(keys)
-
-			for _, mapKey := range keys {
-				mapValue := objValue.MapIndex(reflect.ValueOf(mapKey))
-
-				v, ok := convertValue(mapValue.Interface())
-				if !ok {
-					return fmt.Errorf("Couldn't convert value of key %s: %#v", mapKey, mapValue)
-				}
-
-				setCty(body, mapKey, v)
-			}
-
-		default:
-			return fmt.Errorf("Unknown type: %T", t)
+		src, err := r.render(job.Job)
+		if err != nil {
+			return errors.WithMessagef(err, "Job %s in namespace %s", job.Name, job.Namespace)
 		}
 
-	case reflect.Struct:
-		if len(parent.Attributes()) > 0 || len(parent.Blocks()) > 0 {
-			parent.AppendNewline()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
 		}
 
-		structFields := []*structField{}
-		var blockLabel string
-
-		for i := 0; i < objType.NumField(); i++ {
-			field := objType.Field(i)
-			tag := field.Tag.Get("hcl")
-			if tag == "" {
-				continue
-			}
-
-			tags := strings.Split(tag, ",")
-			var name string
-			block := false
-			optional := false
-			label := false
-
-			for j, elem := range tags {
-				if j == 0 {
-					name = elem
-				} else if elem == "block" {
-					block = true
-				} else if elem == "optional" {
-					optional = true
-				} else if elem == "label" {
-					label = true
-				} else {
-					logger.Fatalf("Unknown hcl tag: %s", elem)
-				}
-			}
-
-			fieldValue := objValue.Field(i)
-
-			if label {
-				if fieldValue.Kind() == reflect.Ptr {
-					blockLabel = fieldValue.Elem().String()
-				} else {
-					blockLabel = fieldValue.String()
-				}
-			} else if name != "" {
-				var structValue interface{}
-
-				if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
-					structValue = fieldValue.Elem().Interface()
-				} else {
-					structValue = fieldValue.Interface()
-				}
-
-				// fmt.Printf("block:%-5v optional:%-5v label:%-5v name:%-20s value:%#v\n", block, optional, label, name, structValue)
-				structFields = append(structFields, &structField{name, block, optional, label, structValue})
-			}
-
-			// why is job special?
-			if key == "job" && name == "name" && blockLabel == "" {
-				if fieldValue.Kind() == reflect.Ptr {
-					blockLabel = fieldValue.Elem().String()
-				} else {
-					blockLabel = fieldValue.String()
-				}
-			}
-		}
-
-		var body *hclwrite.Body
-
-		if key == "" {
-			body = parent
-		} else {
-			if blockLabel == "" {
-				body = parent.AppendNewBlock(key, []string{}).Body()
-			} else {
-				body = parent.AppendNewBlock(key, []string{blockLabel}).Body()
-			}
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			return err
 		}
 
-		for _, field := range structFields {
-			switch {
-			case field.Block && field.Optional:
-				fmt.Printf("%15s %5v %5v %5v: %#v\n", field.Name, field.Block, field.Optional, field.Label, field.Value)
-			case field.Block && !field.Optional:
-				// fmt.Printf("%15s %5v %5v %5v: %#v\n", field.Name, field.Block, field.Optional, field.Label, field.Value)
-				err := convert(body, field.Name, field.Value)
-				if err != nil {
-					return err
-				}
-			case !field.Block && field.Optional:
-				if key == "job" && field.Name == "name" {
-					continue
-				}
-				if c := field.Cty(); c != cty.NilVal {
-					setCty(body, field.Name, c)
-				}
-			case !field.Block && !field.Optional:
-				fmt.Printf("%15s %5v %5v %5v: %#v\n", field.Name, field.Block, field.Optional, field.Label, field.Value)
-			}
-		}
-	case reflect.Slice:
-		// body := parent.AppendNewBlock(key, []string{}).Body()
-		for i := 0; i < objValue.Len(); i++ {
-			elem := objValue.Index(i)
-			if elem.Kind() == reflect.Ptr {
-				elem = elem.Elem()
-			}
-			err := convert(parent, key, elem.Interface())
-			if err != nil {
-				return err
-			}
-		}
-	default:
-		fmt.Println("objType.Kind:", objType.Kind(), "objValue.kind:", objValue.Kind())
-		fmt.Printf("%#v\n", obj)
+		fmt.Fprintf(index, "%s %s %s\n", job.Namespace, job.Name, path)
 	}
 
 	return nil
 }
-
-func setCty(body *hclwrite.Body, key string, c cty.Value) {
-	if c.Type() == cty.String {
-		s := c.AsString()
-		if strings.ContainsAny(s, "\n") {
-			if !strings.HasSuffix(s, "\n") {
-				s = s + "\n"
-			}
-
-			// we do non-indentent heredocs because figuring out the indentation
-			// level requires passing it everywhere.
-			body.SetAttributeRaw(key, hclwrite.Tokens{
-				{Type: hclsyntax.TokenOHeredoc, Bytes: []byte(`<<HEREDOC`), SpacesBefore: 0},
-				{Type: hclsyntax.TokenStringLit, Bytes: []byte("\n" + s), SpacesBefore: 0},
-				{Type: hclsyntax.TokenCHeredoc, Bytes: []byte(`HEREDOC`), SpacesBefore: 0},
-			})
-		} else {
-			s = strings.ReplaceAll(s, `"`, `\"`)
-			body.SetAttributeRaw(key, hclwrite.Tokens{
-				{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`), SpacesBefore: 0},
-				{Type: hclsyntax.TokenStringLit, Bytes: []byte(s), SpacesBefore: 0},
-				{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`), SpacesBefore: 0},
-			})
-		}
-	} else {
-		body.SetAttributeValue(key, c)
-	}
-}
-
-func convertValue(value interface{}) (cv cty.Value, ok bool) {
-	switch v := value.(type) {
-	case string:
-		return cty.StringVal(v), true
-	case *string:
-		if v != nil {
-			return cty.StringVal(*v), true
-		}
-	case int8:
-		return cty.NumberIntVal(int64(v)), true
-	case int:
-		return cty.NumberIntVal(int64(v)), true
-	case *int:
-		if v != nil {
-			return cty.NumberIntVal(int64(*v)), true
-		}
-	case *bool:
-		if v != nil {
-			return cty.BoolVal(*v), true
-		}
-	case nil:
-		return cty.NilVal, true
-	case bool:
-		return cty.BoolVal(v), true
-	case time.Duration:
-		return cty.StringVal(v.String()), true
-	case *time.Duration:
-		if v != nil {
-			return cty.StringVal(v.String()), true
-		}
-	case []interface{}:
-		if len(v) == 0 {
-			return cty.ListValEmpty(cty.String), true
-		} else {
-			list := []cty.Value{}
-			for _, value := range v {
-				converted, ok := convertValue(value)
-				if !ok {
-					fmt.Printf("Couldn't convert value: %T\n", value)
-					return cv, false
-				}
-				list = append(list, converted)
-			}
-			return cty.ListVal(list), true
-		}
-	case []string:
-		if len(v) != 0 {
-			converted := []cty.Value{}
-			for _, value := range v {
-				converted = append(converted, cty.StringVal(value))
-			}
-			return cty.ListVal(converted), true
-		}
-	case map[string]string:
-		if len(v) != 0 {
-			converted := map[string]cty.Value{}
-			for mk, mv := range v {
-				converted[mk] = cty.StringVal(mv)
-			}
-			return cty.MapVal(converted), true
-		}
-	case map[string][]string:
-		if len(v) != 0 {
-			convertedMap := map[string]cty.Value{}
-			for mk, mv := range v {
-				convertedList, ok := convertValue(mv)
-				if !ok {
-					return cv, false
-				}
-				convertedMap[mk] = convertedList
-			}
-			return cty.MapVal(convertedMap), true
-		}
-	case map[string]interface{}:
-		if len(v) != 0 {
-			convertedMap := map[string]cty.Value{}
-			for mk, mv := range v {
-				converted, ok := convertValue(mv)
-				if !ok {
-					return cv, false
-				}
-				convertedMap[mk] = converted
-			}
-			return cty.ObjectVal(convertedMap), true
-		}
-	default:
-		panic(fmt.Sprintf("Unknown type for: %t, %#t %#v", v, v, v))
-	}
-
-	return
-}
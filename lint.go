@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/input-output-hk/bitte-iogo/pkg/lint"
+)
+
+type LintCmd struct {
+	Namespace string `arg:"--namespace" help:"only lint the jobs in this namespace"`
+	Job       string `arg:"positional" help:"only lint this job"`
+	Strict    bool   `arg:"--strict" help:"fail on warnings as well"`
+	ListRules bool   `arg:"--list-rules" help:"list the rules instead"`
+}
+
+// runLint lints every job of the source, or the ones asked for.
+func runLint(args *LintCmd) error {
+	if args.ListRules {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%s %s: %s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		return nil
+	}
+
+	jobs, err := listJobs()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := sourceSecrets()
+	if err != nil {
+		return err
+	}
+	redactor.Deny(secrets...)
+
+	diags := diag.Diagnostics{}
+	linted := 0
+
+	for _, job := range jobs {
+		if (args.Namespace != "" && job.Namespace != args.Namespace) || (args.Job != "" && job.Name != args.Job) {
+			continue
+		}
+
+		found := job.Job
+		if found == nil {
+			if found, err = loadJob(job.Namespace, job.Name); err != nil {
+				return err
+			}
+		} else if err := redactor.Learn(found); err != nil {
+			return err
+		}
+
+		diags = append(diags, lint.Lint(found)...)
+		linted++
+	}
+
+	if linted == 0 {
+		return fmt.Errorf("No jobs to lint")
+	}
+
+	return reportLint(diags, args.Strict)
+}
+
+// lintJob reports what the lint rules find in job, before it is rendered.
+func lintJob(job *api.Job, strict bool) error {
+	return reportLint(lint.Lint(job), strict)
+}
+
+// reportLint fails on lint errors, and on warnings with --strict.
+func reportLint(diags diag.Diagnostics, strict bool) error {
+	if err := diagnostics.Report(diags, strict); err != nil {
+		return err
+	}
+
+	if errors := diags.Errors(); len(errors) > 0 {
+		return fmt.Errorf("%d lint errors, fix them or suppress their rules with meta %s", len(errors), lint.IgnoreMeta)
+	}
+
+	return nil
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/alexflint/go-arg"
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
 )
 
 var buildVersion = "dev"
@@ -16,24 +19,46 @@ var buildCommit = "dirty"
 
 const cue = "cue"
 
-var logger = log.New(os.Stderr, "DEBUG: ", log.LstdFlags)
+// logger masks secrets like every other output meant for people, unless
+// --reveal is given.
+var logger = log.New(redactor.Writer(os.Stderr), "DEBUG: ", log.LstdFlags)
+
+// diagnostics is where every command reports its diagnostics, configured
+// with --diagnostics.
+var diagnostics = diag.NewWriter(terminalWriter(os.Stderr))
 
 type PlanCmd struct {
-	Namespace string `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
-	Job       string `arg:"positional,env:NOMAD_JOB,required"`
-	Output    string `arg:"-o" help:"output" placeholder:"FILE"`
+	Namespace    string `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
+	Job          string `arg:"positional,env:NOMAD_JOB,required"`
+	Output       string `arg:"-o" help:"output" placeholder:"FILE"`
+	Strict       bool   `arg:"--strict" help:"fail on warnings from lint or the converter"`
+	Annotate     bool   `arg:"--annotate" help:"comment groups and tasks with the CUE file and line that defined them"`
+	NomadVersion string `arg:"--nomad-version,env:IOGO_NOMAD_VERSION" help:"leave out fields this Nomad version doesn't know, auto asks the agent" placeholder:"VERSION"`
 }
 
 type RenderCmd struct {
-	Namespace string `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
-	Job       string `arg:"positional,env:NOMAD_JOB,required"`
-	Output    string `arg:"-o" help:"output" placeholder:"FILE"`
+	Namespace    string   `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
+	Job          string   `arg:"positional,env:NOMAD_JOB,required"`
+	Output       string   `arg:"-o" help:"output" placeholder:"FILE"`
+	Format       string   `arg:"--format" default:"hcl2" help:"write hcl2, hcl1, json or api-json (the body of PUT /v1/jobs)"`
+	Canonicalize bool     `arg:"--canonicalize" help:"fill in Nomad's defaults, with --format json or api-json"`
+	Verify       bool     `arg:"--verify" help:"parse the generated HCL again and fail if it differs from the job"`
+	Heredoc      string   `arg:"--heredoc" default:"indent" help:"how to write multi-line strings: indent, flat or never"`
+	Coverage     bool     `arg:"--coverage" help:"list fields of the job that are missing from the HCL"`
+	Strict       bool     `arg:"--strict" help:"fail on warnings from lint, the converter or --coverage"`
+	Annotate     bool     `arg:"--annotate" help:"comment groups and tasks with the CUE file and line that defined them"`
+	ExtractVars  bool     `arg:"--extract-vars" help:"lift values that appear more than once into variable blocks"`
+	ExtractVar   []string `arg:"--extract-var,separate" help:"lift VALUE into a variable block called NAME" placeholder:"NAME=VALUE"`
+	NomadVersion string   `arg:"--nomad-version,env:IOGO_NOMAD_VERSION" help:"leave out fields this Nomad version doesn't know, auto asks the agent" placeholder:"VERSION"`
 }
 
 type RunCmd struct {
-	Namespace string `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
-	Job       string `arg:"positional,env:NOMAD_JOB,required"`
-	Output    string `arg:"-o" help:"output" placeholder:"FILE"`
+	Namespace    string `arg:"--namespace,env:NOMAD_NAMESPACE,required"`
+	Job          string `arg:"positional,env:NOMAD_JOB,required"`
+	Output       string `arg:"-o" help:"output" placeholder:"FILE"`
+	Strict       bool   `arg:"--strict" help:"fail on warnings from lint or the converter"`
+	Annotate     bool   `arg:"--annotate" help:"comment groups and tasks with the CUE file and line that defined them"`
+	NomadVersion string `arg:"--nomad-version,env:IOGO_NOMAD_VERSION" help:"leave out fields this Nomad version doesn't know, auto asks the agent" placeholder:"VERSION"`
 }
 
 type ListJobsCmd struct {
@@ -46,6 +71,18 @@ type ListNamespacesCmd struct {
 
 type iogo struct {
 	Debug          bool               `arg:"--debug" help:"debugging output"`
+	Diagnostics    string             `arg:"--diagnostics,env:IOGO_DIAGNOSTICS" default:"text" help:"write warnings to stderr as text or json"`
+	Config         string             `arg:"--config,env:IOGO_CONFIG" default:".iogo.json" help:"project config file" placeholder:"FILE"`
+	Reveal         bool               `arg:"--reveal" help:"print secrets to the terminal instead of masking them"`
+	NoCache        bool               `arg:"--no-cache,env:IOGO_NO_CACHE" help:"vet and export the CUE sources even if they didn't change"`
+	CueDir         string             `arg:"--cue-dir,env:IOGO_CUE_DIR" help:"directory of the CUE sources, the current one by default" placeholder:"DIR"`
+	CuePackage     string             `arg:"--cue-package,env:IOGO_CUE_PACKAGE" help:"CUE package with the jobs" placeholder:"PACKAGE"`
+	Expression     string             `arg:"--expression,env:IOGO_EXPRESSION" help:"CUE expression with the jobs by namespace and name, rendered by default" placeholder:"EXPR"`
+	Tags           []string           `arg:"--tag,separate,env:IOGO_TAGS" help:"inject KEY=VALUE into the CUE sources with cue -t" placeholder:"KEY=VALUE"`
+	Source         string             `arg:"--source,env:IOGO_SOURCE" help:"where jobs are defined: cue, nix or json, cue by default"`
+	NixAttribute   string             `arg:"--nix-attribute,env:IOGO_NIX_ATTRIBUTE" help:"Nix attribute with the jobs by namespace and name, for --source nix" placeholder:"ATTR"`
+	JobsDir        string             `arg:"--jobs-dir,env:IOGO_JOBS_DIR" help:"directory with <namespace>/<job>.json files, for --source json" placeholder:"DIR"`
+	FromExport     string             `arg:"--from-export,env:IOGO_FROM_EXPORT" help:"read jobs from a file written by iogo export instead of CUE" placeholder:"FILE"`
 	Plan           *PlanCmd           `arg:"subcommand:plan"`
 	Render         *RenderCmd         `arg:"subcommand:render"`
 	Run            *RunCmd            `arg:"subcommand:run"`
@@ -53,6 +90,10 @@ type iogo struct {
 	ListNamespaces *ListNamespacesCmd `arg:"subcommand:list-namespaces"`
 	Login          *LoginCmd          `arg:"subcommand:login"`
 	Json2Hcl       *Json2HclCmd       `arg:"subcommand:json2hcl"`
+	Hcl2Json       *Hcl2JsonCmd       `arg:"subcommand:hcl2json"`
+	Cache          *CacheCmd          `arg:"subcommand:cache"`
+	Export         *ExportCmd         `arg:"subcommand:export" help:"save all jobs from CUE for --from-export"`
+	Lint           *LintCmd           `arg:"subcommand:lint" help:"check jobs for mistakes that Nomad or CUE don't catch"`
 }
 
 func Version() string {
@@ -68,10 +109,24 @@ func main() {
 	parser, err := parseArgs(args)
 	fail(parser, err)
 
-	if args.Debug {
-		logger.SetOutput(os.Stderr)
+	fail(parser, diagnostics.SetFormat(args.Diagnostics))
+
+	config, err := loadConfig(args.Config)
+	fail(parser, err)
+
+	redactor.SetRules(config.Redact)
+
+	if args.NoCache {
+		exportCache = nil
 	}
 
+	cueSettings, err = cueSettingsFor(args, config.Cue)
+	fail(parser, err)
+
+	jobSource, err = jobSourceFor(args, config)
+	fail(parser, err)
+	redactor.SetReveal(args.Reveal)
+
 	fail(parser, run(parser, args))
 }
 
@@ -86,7 +141,7 @@ func fail(parser *arg.Parser, err error) {
 		fmt.Fprintln(os.Stdout, Version())
 		os.Exit(0)
 	default:
-		fmt.Fprint(os.Stderr, err, "\n")
+		fmt.Fprint(terminalWriter(os.Stderr), err, "\n")
 		os.Exit(1)
 	}
 }
@@ -116,6 +171,14 @@ func run(parser *arg.Parser, args *iogo) error {
 		return args.Login.runLogin()
 	case args.Json2Hcl != nil:
 		return runJson2Hcl(args.Json2Hcl)
+	case args.Hcl2Json != nil:
+		return runHcl2Json(args.Hcl2Json)
+	case args.Cache != nil:
+		return args.Cache.runCache()
+	case args.Export != nil:
+		return runExport(args.Export)
+	case args.Lint != nil:
+		return runLint(args.Lint)
 	default:
 		parser.WriteHelp(os.Stderr)
 	}
@@ -124,7 +187,7 @@ func run(parser *arg.Parser, args *iogo) error {
 }
 
 func runListJobs(args *ListJobsCmd) error {
-	export, err := cueExport()
+	jobs, err := listJobs()
 	if err != nil {
 		return err
 	}
@@ -134,17 +197,15 @@ func runListJobs(args *ListJobsCmd) error {
 		return err
 	}
 
-	for namespace, jobs := range export.Rendered {
-		for job := range jobs {
-			fmt.Fprintf(out, "%s %s\n", namespace, job)
-		}
+	for _, job := range jobs {
+		fmt.Fprintf(out, "%s %s\n", job.Namespace, job.Name)
 	}
 
 	return nil
 }
 
 func runListNamespaces(args *ListNamespacesCmd) error {
-	export, err := cueExport()
+	namespaces, err := listNamespaces()
 	if err != nil {
 		return err
 	}
@@ -154,7 +215,7 @@ func runListNamespaces(args *ListNamespacesCmd) error {
 		return err
 	}
 
-	for namespace := range export.Rendered {
+	for _, namespace := range namespaces {
 		fmt.Fprintf(out, "%s\n", namespace)
 	}
 
@@ -162,51 +223,149 @@ func runListNamespaces(args *ListNamespacesCmd) error {
 }
 
 func runRender(args *RenderCmd) error {
-	export, err := cueExport()
+	job, err := loadJob(args.Namespace, args.Job)
 	if err != nil {
 		return err
 	}
 
-	if namespace, ok := export.Rendered[args.Namespace]; ok {
-		if job, ok := namespace[args.Job]; ok {
-			hcl, err := any2hcl("job", job.Job)
-			if err != nil {
-				return err
-			}
+	if err := lintJob(job, args.Strict); err != nil {
+		return err
+	}
 
-			out, err := openOutput(args.Output)
-			if err != nil {
-				return err
-			}
+	opts, err := renderOptions(args.Namespace, args.Job, job, args.Annotate, args.NomadVersion)
+	if err != nil {
+		return err
+	}
+	opts.Heredoc = args.Heredoc
+	opts.ExtractVars = args.ExtractVars
+	if opts.Variables, err = parseVariables(args.ExtractVar); err != nil {
+		return err
+	}
 
-			_, err = hcl.WriteTo(out)
-			return err
-		} else {
-			return errors.New("Missing job in namespace")
-		}
+	r := &jobRenderer{
+		Format:       args.Format,
+		Canonicalize: args.Canonicalize,
+		Verify:       args.Verify,
+		Coverage:     args.Coverage,
+		Strict:       args.Strict,
+		Options:      opts,
+	}
+
+	masked, err := redactOutput(args.Output, job)
+	if err != nil {
+		return err
+	}
+
+	src, err := r.render(masked)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput(args.Output)
+	if err != nil {
+		return err
 	}
 
-	return errors.New("Missing namespace")
+	// JSON has no comments to put the provenance in
+	if !r.isHcl() {
+		_, err = out.Write(src)
+		return err
+	}
+
+	provenance, err := newProvenance(args.Namespace, args.Job)
+	if err != nil {
+		return err
+	}
+
+	return writeHcl(out, provenance, src)
 }
 
 func runRun(args *RunCmd) error {
-	return nomadJobDo(args.Namespace, args.Job, args.Output, "run")
+	return nomadJobDo(args.Namespace, args.Job, args.Output, "run", args.NomadVersion, args.Strict, args.Annotate)
 }
 
 func runPlan(args *PlanCmd) error {
-	return nomadJobDo(args.Namespace, args.Job, args.Output, "plan")
+	return nomadJobDo(args.Namespace, args.Job, args.Output, "plan", args.NomadVersion, args.Strict, args.Annotate)
+}
+
+// renderOptions are the hcl.Options for jobs rendered from CUE.
+func renderOptions(namespace, name string, job *api.Job, annotate bool, version string) (hcl.Options, error) {
+	opts := hcl.Options{}
+
+	version, err := nomadVersion(version)
+	if err != nil {
+		return opts, err
+	}
+	opts.NomadVersion = version
+
+	if annotate {
+		if !isCueSource() {
+			return opts, fmt.Errorf("--annotate only works with --source %s", sourceCue)
+		}
+
+		annotations, err := cueAnnotations(cueSource(), namespace, name, job)
+		if err != nil {
+			return opts, err
+		}
+		opts.Annotate = annotations
+	}
+
+	return opts, nil
+}
+
+// parseVariables turns NAME=VALUE pairs of --extract-var into
+// hcl.Options.Variables.
+func parseVariables(pairs []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid --extract-var %q, use NAME=VALUE", pair)
+		}
+		vars[parts[0]] = parts[1]
+	}
+
+	return vars, nil
 }
 
-func nomadJobDo(namespace, job, output, action string) error {
-	hcl, err := cue2hcl(namespace, job)
+func nomadJobDo(namespace, job, output, action, version string, strict, annotate bool) error {
+	found, err := loadJob(namespace, job)
 	if err != nil {
 		return err
 	}
 
+	if err := lintJob(found, strict); err != nil {
+		return err
+	}
+
+	opts, err := renderOptions(namespace, job, found, annotate, version)
+	if err != nil {
+		return err
+	}
+
+	file, diags, err := hcl.Render(found, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := diagnostics.Report(diags, strict); err != nil {
+		return err
+	}
+
+	provenance, err := newProvenance(namespace, job)
+	if err != nil {
+		return err
+	}
+
+	// nomad gets the job as it is, but what it prints is masked
+	stdout, stderr := terminalWriter(os.Stdout), terminalWriter(os.Stderr)
+	defer flush(stderr)
+	defer flush(stdout)
+
 	if isStdpipe(output) {
 		cmd := exec.Command("nomad", "job", action, "-")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
 
 		stdin, err := cmd.StdinPipe()
 		if err != nil {
@@ -214,7 +373,7 @@ func nomadJobDo(namespace, job, output, action string) error {
 		}
 
 		go func() {
-			_, err = hcl.WriteTo(stdin)
+			err = writeHcl(stdin, provenance, file.Bytes())
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "error while while generating HCL:\n%s\n", err)
 				os.Exit(1)
@@ -230,14 +389,14 @@ func nomadJobDo(namespace, job, output, action string) error {
 			return err
 		}
 
-		_, err = hcl.WriteTo(out)
+		err = writeHcl(out, provenance, file.Bytes())
 		if err != nil {
 			return err
 		}
 
 		cmd := exec.Command("nomad", "job", action, output)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
 		return cmd.Run()
 	}
 }
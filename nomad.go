@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/hashicorp/nomad/api"
+	"github.com/pkg/errors"
+)
+
+// nomadVersion resolves --nomad-version: auto asks the agent at NOMAD_ADDR,
+// anything else is used as it is.
+func nomadVersion(flag string) (string, error) {
+	if flag != "auto" {
+		return flag, nil
+	}
+
+	return agentVersion(api.DefaultConfig())
+}
+
+// agentVersion returns the version of the Nomad agent config points to.
+func agentVersion(config *api.Config) (string, error) {
+	client, err := api.NewClient(config)
+	if err != nil {
+		return "", err
+	}
+
+	self, err := client.Agent().Self()
+	if err != nil {
+		return "", errors.WithMessage(err, "Asking the Nomad agent for its version")
+	}
+
+	if info, ok := self.Config["Version"].(map[string]interface{}); ok {
+		if version, ok := info["Version"].(string); ok && version != "" {
+			return version, nil
+		}
+	}
+
+	if version := self.Member.Tags["build"]; version != "" {
+		return version, nil
+	}
+
+	return "", errors.New("The Nomad agent didn't tell its version, use --nomad-version instead")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentVersion(t *testing.T) {
+	r := require.New(t)
+
+	body := `{"config": {"Version": {"Version": "1.1.4", "VersionPrerelease": ""}}, "member": {}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.Equal("/v1/agent/self", req.URL.Path)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+
+	version, err := agentVersion(config)
+	r.Nil(err)
+	r.Equal("1.1.4", version)
+
+	body = `{"config": {}, "member": {"Tags": {"build": "1.0.4"}}}`
+	version, err = agentVersion(config)
+	r.Nil(err)
+	r.Equal("1.0.4", version)
+
+	body = `{"config": {}, "member": {}}`
+	_, err = agentVersion(config)
+	r.EqualError(err, "The Nomad agent didn't tell its version, use --nomad-version instead")
+
+	version, err = nomadVersion("0.12.4")
+	r.Nil(err)
+	r.Equal("0.12.4", version)
+}
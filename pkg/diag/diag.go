@@ -0,0 +1,191 @@
+// Package diag has the diagnostics that iogo reports about jobs: messages
+// that don't stop it, like a value the converter had to leave out.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity of a Diagnostic.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Diagnostic is a message about a job. Diagnostics never go to stdout, so
+// they can't end up in rendered HCL.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Source is the part of iogo that reported it, like converter, coverage
+	// or cue vet.
+	Source string `json:"source"`
+	// Rule is the ID of the lint rule that found it.
+	Rule string `json:"rule,omitempty"`
+	// Path through the job tree, like job["web"].group["api"].task["server"],
+	// or the CUE path for errors from cue.
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	// File, Line and Column point into the source the message is about,
+	// Snippet is the text of that line.
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Location is file:line:column, or as much of it as is known.
+func (d *Diagnostic) Location() string {
+	switch {
+	case d.File == "":
+		return ""
+	case d.Line == 0:
+		return d.File
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+}
+
+func (d *Diagnostic) String() string {
+	source := d.Source
+	if d.Rule != "" {
+		source += " " + d.Rule
+	}
+
+	parts := []string{string(d.Severity), source}
+	if location := d.Location(); location != "" {
+		parts = append(parts, location)
+	}
+	if d.Path != "" {
+		parts = append(parts, d.Path)
+	}
+	parts = append(parts, d.Message)
+
+	s := strings.Join(parts, ": ")
+	if d.Snippet != "" && d.Line > 0 {
+		s += "\n" + d.snippet()
+	}
+
+	return s
+}
+
+// snippet shows the line with a caret under the column, like
+//
+//	12 | datacenters: 1
+//	   |              ^
+func (d *Diagnostic) snippet() string {
+	number := fmt.Sprint(d.Line)
+	margin := strings.Repeat(" ", len(number))
+	s := fmt.Sprintf("  %s | %s", number, d.Snippet)
+
+	if d.Column > 0 && d.Column <= len(d.Snippet)+1 {
+		// keep the tabs, so the caret lines up with the snippet
+		indent := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, d.Snippet[:d.Column-1])
+		s += fmt.Sprintf("\n  %s | %s^", margin, indent)
+	}
+
+	return s
+}
+
+// Diagnostics is a list of Diagnostic. It is an error so --strict can fail
+// with the warnings it found.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, len(d))
+	for i, diag := range d {
+		msgs[i] = diag.String()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Errors returns the diagnostics with error severity.
+func (d Diagnostics) Errors() Diagnostics {
+	errors := Diagnostics{}
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			errors = append(errors, diag)
+		}
+	}
+
+	return errors
+}
+
+// Warnings returns the diagnostics with warning severity or worse.
+func (d Diagnostics) Warnings() Diagnostics {
+	warnings := Diagnostics{}
+	for _, diag := range d {
+		if diag.Severity != SeverityInfo {
+			warnings = append(warnings, diag)
+		}
+	}
+
+	return warnings
+}
+
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+// Writer writes diagnostics either as text lines or as one JSON object per
+// line.
+type Writer struct {
+	out    io.Writer
+	format string
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out, format: FormatText}
+}
+
+func (w *Writer) SetFormat(format string) error {
+	switch format {
+	case FormatText, FormatJson:
+		w.format = format
+		return nil
+	}
+
+	return fmt.Errorf("Unknown diagnostics format %q, use one of %s or %s", format, FormatText, FormatJson)
+}
+
+func (w *Writer) Write(diags Diagnostics) error {
+	for _, diag := range diags {
+		if w.format == FormatJson {
+			if err := json.NewEncoder(w.out).Encode(diag); err != nil {
+				return err
+			}
+		} else if _, err := fmt.Fprintln(w.out, diag.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Report writes diags and, if strict is set, fails when any of them is a
+// warning.
+func (w *Writer) Report(diags Diagnostics, strict bool) error {
+	if err := w.Write(diags); err != nil {
+		return err
+	}
+
+	if warnings := diags.Warnings(); strict && len(warnings) > 0 {
+		return fmt.Errorf("%d warnings with --strict", len(warnings))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	r := require.New(t)
+
+	diags := Diagnostics{
+		{Severity: SeverityInfo, Source: "cue vet", Message: "some note"},
+		{Severity: SeverityWarning, Source: "converter", Path: `job["docs"].group[0]`, Message: "block has an empty name, Nomad will reject it"},
+	}
+
+	out := &bytes.Buffer{}
+	w := NewWriter(out)
+	r.Nil(w.Report(diags, false))
+	r.Equal(`info: cue vet: some note
+warning: converter: job["docs"].group[0]: block has an empty name, Nomad will reject it
+`, out.String())
+
+	out.Reset()
+	r.Nil(w.SetFormat(FormatJson))
+	r.EqualError(w.Report(diags, true), "1 warnings with --strict")
+	r.Equal(`{"severity":"info","source":"cue vet","message":"some note"}
+{"severity":"warning","source":"converter","path":"job[\"docs\"].group[0]","message":"block has an empty name, Nomad will reject it"}
+`, out.String())
+
+	out.Reset()
+	r.Nil(w.Report(diags[:1], true))
+	r.EqualError(w.SetFormat("xml"), `Unknown diagnostics format "xml", use one of text or json`)
+}
+
+func TestDiagnosticSnippet(t *testing.T) {
+	r := require.New(t)
+
+	d := &Diagnostic{
+		Severity: SeverityError,
+		Source:   "cue vet",
+		Path:     "rendered.prod.web",
+		Message:  "conflicting values",
+		File:     "jobs/web.cue",
+		Line:     12,
+		Column:   15,
+		Snippet:  "\tdatacenters: 1",
+	}
+
+	r.Equal("error: cue vet: jobs/web.cue:12:15: rendered.prod.web: conflicting values\n"+
+		"  12 | \tdatacenters: 1\n"+
+		"     | \t             ^", d.String())
+
+	d.Column, d.Snippet = 0, ""
+	r.Equal("error: cue vet: jobs/web.cue:12: rendered.prod.web: conflicting values", d.String())
+}
@@ -0,0 +1,573 @@
+package hcl
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/nomad/api"
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+)
+
+// Parse decodes the job block in src, like Nomad's own HCL parser does.
+func Parse(src []byte, filename string) (*api.Job, error) {
+	job := &api.Job{}
+	if err := hcl2any(src, filename, "job", job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// hcl2any is the reverse of any2hcl: it decodes the single top-level block
+// named key in src into target, which must be a pointer to a struct with the
+// same `hcl` tags that convert() understands. References to `variable`
+// blocks resolve to their defaults.
+func hcl2any(src []byte, filename, key string, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Can't decode into non-pointer %T", target)
+	}
+
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	body := file.Body.(*hclsyntax.Body)
+
+	for _, attr := range sortedAttributes(body) {
+		return diagError(attr.NameRange, "Unsupported argument", fmt.Sprintf("An argument named %q is not expected here.", attr.Name))
+	}
+
+	d := &hclDecoder{src: src}
+	vars := map[string]cty.Value{}
+
+	var found *hclsyntax.Block
+	for _, block := range body.Blocks {
+		if block.Type == "variable" && key != "variable" {
+			name, value, err := d.variable(block)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := vars[name]; ok {
+				return diagError(block.LabelRanges[0], "Duplicate variable", fmt.Sprintf("The variable %q is already declared.", name))
+			}
+
+			vars[name] = value
+			continue
+		}
+
+		if block.Type != key {
+			return diagError(block.TypeRange, "Unsupported block type", fmt.Sprintf("Blocks of type %q are not expected here.", block.Type))
+		}
+
+		if found != nil {
+			return diagError(block.TypeRange, "Duplicate block", fmt.Sprintf("Only one %q block is allowed.", key))
+		}
+
+		found = block
+	}
+
+	if found == nil {
+		return fmt.Errorf("Missing %s block in %s", key, filename)
+	}
+
+	if len(vars) > 0 {
+		d.ctx = &hcl.EvalContext{Variables: map[string]cty.Value{"var": cty.ObjectVal(vars)}}
+	}
+
+	return d.decodeBlock(key, found, rv.Elem(), false)
+}
+
+type hclDecoder struct {
+	src []byte
+	// ctx has the variables, nil if there are none
+	ctx *hcl.EvalContext
+}
+
+// variable decodes a `variable` block, which needs a default because there
+// is no way to set it.
+func (d *hclDecoder) variable(block *hclsyntax.Block) (string, cty.Value, error) {
+	if len(block.Labels) != 1 {
+		return "", cty.NilVal, diagError(block.TypeRange, "Missing variable name", "A variable block needs exactly one label, its name.")
+	}
+
+	for _, attr := range sortedAttributes(block.Body) {
+		switch attr.Name {
+		case "default", "type", "description":
+		default:
+			return "", cty.NilVal, diagError(attr.NameRange, "Unsupported argument", fmt.Sprintf("An argument named %q is not expected here.", attr.Name))
+		}
+	}
+
+	attr, ok := block.Body.Attributes["default"]
+	if !ok {
+		return "", cty.NilVal, diagError(block.TypeRange, "Missing variable default", fmt.Sprintf("The variable %q has no default.", block.Labels[0]))
+	}
+
+	value, err := d.value(attr.Expr)
+	return block.Labels[0], value, err
+}
+
+func (d *hclDecoder) decodeBlock(key string, block *hclsyntax.Block, target reflect.Value, mapEntry bool) error {
+	target = allocate(target)
+	if target.Kind() != reflect.Struct {
+		return diagError(block.TypeRange, "Unsupported block type", fmt.Sprintf("Can't decode %q block into %s", block.Type, target.Type()))
+	}
+
+	objType := target.Type()
+	attrs := map[string]reflect.Value{}
+	blocks := map[string]reflect.Value{}
+	labels := []reflect.Value{}
+
+	for i := 0; i < objType.NumField(); i++ {
+		tag := objType.Field(i).Tag.Get("hcl")
+		if tag == "" {
+			continue
+		}
+
+		name, isBlock, _, isLabel, err := parseHclTag(tag)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case isLabel:
+			labels = append(labels, target.Field(i))
+		case name == "" || name == "-":
+		case isBlock || isStructValue(target.Field(i).Interface()):
+			blocks[name] = target.Field(i)
+		default:
+			attrs[name] = target.Field(i)
+		}
+	}
+
+	// why is job special? see convert()
+	if key == "job" && len(labels) == 0 {
+		if field, ok := attrs["name"]; ok {
+			labels = append(labels, field)
+		}
+	}
+
+	// map entries carry their key as label even if the struct doesn't have a
+	// field for it.
+	if len(block.Labels) != len(labels) && !(mapEntry && len(labels) == 0) {
+		return diagError(block.TypeRange, "Wrong number of labels", fmt.Sprintf("Blocks of type %q need %d labels, got %d.", block.Type, len(labels), len(block.Labels)))
+	}
+
+	for i, label := range labels {
+		if err := d.assign(block.LabelRanges[i], label, cty.StringVal(block.Labels[i])); err != nil {
+			return err
+		}
+	}
+
+	for _, attr := range sortedAttributes(block.Body) {
+		field, ok := attrs[attr.Name]
+		if !ok {
+			// Nomad also accepts `env = { ... }` instead of `env { ... }`
+			if field, ok = blocks[attr.Name]; !ok || field.Kind() != reflect.Map {
+				return diagError(attr.NameRange, "Unsupported argument", fmt.Sprintf("An argument named %q is not expected in %q.", attr.Name, block.Type))
+			}
+		}
+
+		value, err := d.value(attr.Expr)
+		if err != nil {
+			return err
+		}
+
+		if err := d.assign(attr.Expr.Range(), field, value); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range block.Body.Blocks {
+		field, ok := blocks[child.Type]
+		if !ok {
+			return diagError(child.TypeRange, "Unsupported block type", fmt.Sprintf("Blocks of type %q are not expected in %q.", child.Type, block.Type))
+		}
+
+		if err := d.decodeChild(child, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *hclDecoder) decodeChild(block *hclsyntax.Block, field reflect.Value) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := d.decodeBlock(block.Type, block, elem, false); err != nil {
+			return err
+		}
+
+		field.Set(reflect.Append(field, elem))
+	case reflect.Map:
+		if !isStructType(field.Type().Elem()) {
+			value, err := d.bodyValue(block.Body)
+			if err != nil {
+				return err
+			}
+
+			return d.assign(block.Body.SrcRange, field, value)
+		}
+
+		if len(block.Labels) != 1 {
+			return diagError(block.TypeRange, "Wrong number of labels", fmt.Sprintf("Blocks of type %q need 1 label, got %d.", block.Type, len(block.Labels)))
+		}
+
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := d.decodeBlock(block.Type, block, elem, true); err != nil {
+			return err
+		}
+
+		field.SetMapIndex(reflect.ValueOf(block.Labels[0]), elem)
+	case reflect.Ptr:
+		if !field.IsNil() {
+			return diagError(block.TypeRange, "Duplicate block", fmt.Sprintf("Only one %q block is allowed.", block.Type))
+		}
+
+		return d.decodeBlock(block.Type, block, field, false)
+	default:
+		return d.decodeBlock(block.Type, block, field, false)
+	}
+
+	return nil
+}
+
+// bodyValue turns a free-form body such as a task config into an object.
+// Nested blocks become lists of objects, the way Nomad decodes them.
+func (d *hclDecoder) bodyValue(body *hclsyntax.Body) (cty.Value, error) {
+	obj := map[string]cty.Value{}
+
+	for _, attr := range sortedAttributes(body) {
+		value, err := d.value(attr.Expr)
+		if err != nil {
+			return cty.NilVal, err
+		}
+
+		obj[attr.Name] = value
+	}
+
+	nested := map[string][]cty.Value{}
+	for _, block := range body.Blocks {
+		value, err := d.bodyValue(block.Body)
+		if err != nil {
+			return cty.NilVal, err
+		}
+
+		nested[block.Type] = append(nested[block.Type], value)
+	}
+
+	for name, values := range nested {
+		obj[name] = cty.TupleVal(values)
+	}
+
+	return cty.ObjectVal(obj), nil
+}
+
+// value evaluates expr with the defaults of the variables in scope as var.
+// Like Nomad, it keeps interpolations it can't resolve, such as
+// ${NOMAD_ALLOC_ID} or ${attr.kernel.name}, verbatim so they get evaluated
+// at runtime.
+func (d *hclDecoder) value(expr hclsyntax.Expression) (cty.Value, error) {
+	switch e := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		sb := &strings.Builder{}
+
+		for _, part := range e.Parts {
+			v, diags := part.Value(d.ctx)
+			if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() {
+				sb.WriteString("${" + string(part.Range().SliceBytes(d.src)) + "}")
+				continue
+			}
+
+			s, err := ctyconvert.Convert(v, cty.String)
+			if err != nil {
+				return cty.NilVal, diagError(part.Range(), "Invalid template interpolation value", err.Error())
+			}
+
+			sb.WriteString(s.AsString())
+		}
+
+		return cty.StringVal(sb.String()), nil
+	case *hclsyntax.TemplateWrapExpr:
+		v, diags := e.Wrapped.Value(d.ctx)
+		if diags.HasErrors() || !v.IsWhollyKnown() {
+			return cty.StringVal("${" + string(e.Wrapped.Range().SliceBytes(d.src)) + "}"), nil
+		}
+
+		return v, nil
+	case *hclsyntax.TupleConsExpr:
+		values := []cty.Value{}
+
+		for _, elem := range e.Exprs {
+			v, err := d.value(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			values = append(values, v)
+		}
+
+		return cty.TupleVal(values), nil
+	case *hclsyntax.ObjectConsExpr:
+		obj := map[string]cty.Value{}
+
+		for _, item := range e.Items {
+			k, diags := item.KeyExpr.Value(d.ctx)
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+
+			k, err := ctyconvert.Convert(k, cty.String)
+			if err != nil {
+				return cty.NilVal, diagError(item.KeyExpr.Range(), "Invalid object key", err.Error())
+			}
+
+			v, err := d.value(item.ValueExpr)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			obj[k.AsString()] = v
+		}
+
+		return cty.ObjectVal(obj), nil
+	default:
+		v, diags := expr.Value(d.ctx)
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+
+		return v, nil
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// assign stores value in target, converting it to the Go type of target.
+func (d *hclDecoder) assign(rng hcl.Range, target reflect.Value, value cty.Value) error {
+	if value.IsNull() {
+		return nil
+	}
+
+	if target.Type() == durationType {
+		s, err := ctyconvert.Convert(value, cty.String)
+		if err != nil {
+			return diagError(rng, "Invalid duration", err.Error())
+		}
+
+		duration, err := time.ParseDuration(s.AsString())
+		if err != nil {
+			return diagError(rng, "Invalid duration", err.Error())
+		}
+
+		target.SetInt(int64(duration))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(target.Type().Elem())
+		if err := d.assign(rng, elem.Elem(), value); err != nil {
+			return err
+		}
+
+		target.Set(elem)
+	case reflect.Interface:
+		v, err := ctyToInterface(value)
+		if err != nil {
+			return diagError(rng, "Unsupported value", err.Error())
+		}
+
+		target.Set(reflect.ValueOf(v))
+	case reflect.String:
+		s, err := ctyconvert.Convert(value, cty.String)
+		if err != nil {
+			return diagError(rng, "Incorrect attribute value type", err.Error())
+		}
+
+		target.SetString(s.AsString())
+	case reflect.Bool:
+		b, err := ctyconvert.Convert(value, cty.Bool)
+		if err != nil {
+			return diagError(rng, "Incorrect attribute value type", err.Error())
+		}
+
+		target.SetBool(b.True())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := ctyNumber(value)
+		if err != nil {
+			return diagError(rng, "Incorrect attribute value type", err.Error())
+		}
+
+		i, accuracy := n.Int64()
+		if accuracy != big.Exact || target.OverflowInt(i) {
+			return diagError(rng, "Invalid number", fmt.Sprintf("%s doesn't fit into %s", n.Text('f', -1), target.Type()))
+		}
+
+		target.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := ctyNumber(value)
+		if err != nil {
+			return diagError(rng, "Incorrect attribute value type", err.Error())
+		}
+
+		u, accuracy := n.Uint64()
+		if accuracy != big.Exact || target.OverflowUint(u) {
+			return diagError(rng, "Invalid number", fmt.Sprintf("%s doesn't fit into %s", n.Text('f', -1), target.Type()))
+		}
+
+		target.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		n, err := ctyNumber(value)
+		if err != nil {
+			return diagError(rng, "Incorrect attribute value type", err.Error())
+		}
+
+		f, _ := n.Float64()
+		target.SetFloat(f)
+	case reflect.Slice:
+		if !value.CanIterateElements() || value.Type().IsObjectType() || value.Type().IsMapType() {
+			return diagError(rng, "Incorrect attribute value type", fmt.Sprintf("Expected a list, got %s", value.Type().FriendlyName()))
+		}
+
+		slice := reflect.MakeSlice(target.Type(), 0, value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := d.assign(rng, elem, v); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+
+		target.Set(slice)
+	case reflect.Map:
+		if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+			return diagError(rng, "Incorrect attribute value type", fmt.Sprintf("Expected an object, got %s", value.Type().FriendlyName()))
+		}
+
+		m := reflect.MakeMapWithSize(target.Type(), value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := d.assign(rng, elem, v); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k.AsString()), elem)
+		}
+
+		target.Set(m)
+	default:
+		return diagError(rng, "Unsupported attribute type", fmt.Sprintf("Can't decode into %s", target.Type()))
+	}
+
+	return nil
+}
+
+// ctyToInterface converts value into the same shapes encoding/json produces.
+func ctyToInterface(value cty.Value) (interface{}, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	switch t := value.Type(); {
+	case t == cty.String:
+		return value.AsString(), nil
+	case t == cty.Bool:
+		return value.True(), nil
+	case t == cty.Number:
+		f, _ := value.AsBigFloat().Float64()
+		return f, nil
+	case t.IsObjectType() || t.IsMapType():
+		m := map[string]interface{}{}
+		for it := value.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			converted, err := ctyToInterface(v)
+			if err != nil {
+				return nil, err
+			}
+			m[k.AsString()] = converted
+		}
+		return m, nil
+	case value.CanIterateElements():
+		list := []interface{}{}
+		for it := value.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			converted, err := ctyToInterface(v)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("Unsupported value of type %s", t.FriendlyName())
+	}
+}
+
+func ctyNumber(value cty.Value) (*big.Float, error) {
+	n, err := ctyconvert.Convert(value, cty.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.AsBigFloat(), nil
+}
+
+// allocate follows pointers in v, allocating them if they're nil.
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct
+}
+
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+
+	return attrs
+}
+
+func diagError(rng hcl.Range, summary, detail string) error {
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  summary,
+		Detail:   detail,
+		Subject:  rng.Ptr(),
+	}}
+}
@@ -0,0 +1,83 @@
+package hcl
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHcl2Job(t *testing.T) {
+	r := require.New(t)
+
+	b, err := ioutil.ReadFile("../../job.hcl")
+	r.Nil(err)
+
+	job := &api.Job{}
+	r.Nil(hcl2any(b, "job.hcl", "job", job))
+
+	r.Equal("db-sync-mainnet", *job.Name)
+	r.Equal([]string{"eu-central-1", "us-east-2", "eu-west-1"}, job.Datacenters)
+	r.Equal("${attr.unique.platform.aws.instance-id}", job.Constraints[0].LTarget)
+
+	task := job.TaskGroups[0].Tasks[0]
+	r.Equal("db-sync", task.Name)
+	r.Equal("/bin/cardano-db-sync-extended-entrypoint", task.Config["command"])
+	r.Equal("/alloc/node.socket", task.Env["CARDANO_NODE_SOCKET_PATH"])
+	r.Equal(12288, *task.Resources.MemoryMB)
+	r.Equal(time.Duration(0), task.ShutdownDelay)
+}
+
+func TestHcl2JobInterpolation(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{}
+	r.Nil(hcl2any([]byte(`
+job "docs" {
+  group "example" {
+    task "server" {
+      config {
+        args = ["-port", "${NOMAD_PORT_http}", 8080]
+
+        logging {
+          type = "journald"
+        }
+      }
+
+      env {
+        URL = "http://${NOMAD_UPSTREAM_ADDR_count_api}/${ lower("API") }"
+      }
+    }
+  }
+}
+`), "test.hcl", "job", job))
+
+	task := job.TaskGroups[0].Tasks[0]
+	r.Equal([]interface{}{"-port", "${NOMAD_PORT_http}", float64(8080)}, task.Config["args"])
+	r.Equal([]interface{}{map[string]interface{}{"type": "journald"}}, task.Config["logging"])
+	r.Equal(`http://${NOMAD_UPSTREAM_ADDR_count_api}/${lower("API")}`, task.Env["URL"])
+}
+
+func TestHcl2JobErrors(t *testing.T) {
+	r := require.New(t)
+
+	err := hcl2any([]byte(`
+job "docs" {
+  group "example" {
+    count = "many"
+  }
+}
+`), "test.hcl", "job", &api.Job{})
+	r.EqualError(err, `test.hcl:4,13-19: Incorrect attribute value type; a number is required`)
+
+	err = hcl2any([]byte(`
+job "docs" {
+  group "example" {
+    counts = 1
+  }
+}
+`), "test.hcl", "job", &api.Job{})
+	r.EqualError(err, `test.hcl:4,5-11: Unsupported argument; An argument named "counts" is not expected in "group".`)
+}
@@ -0,0 +1,192 @@
+package hcl
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// configSchema describes the task `config` of a driver, so it can be written
+// the way the driver expects it instead of as plain attributes.
+type configSchema struct {
+	// attributes are the keys written as attributes.
+	attributes map[string]bool
+	// blocks are the keys written as nested blocks. Lists of objects become
+	// one block per element, like docker's `mount`.
+	blocks map[string]*configSchema
+	// open schemas take any key as an attribute, like docker's `port_map`.
+	open bool
+}
+
+func newConfigSchema(attributes []string, blocks map[string]*configSchema) *configSchema {
+	s := &configSchema{attributes: map[string]bool{}, blocks: blocks}
+	for _, name := range attributes {
+		s.attributes[name] = true
+	}
+
+	return s
+}
+
+var openConfigSchema = &configSchema{open: true}
+
+// driverSchemas has the config layout of every driver we know about. Config
+// of other drivers is written as plain attributes.
+var driverSchemas = map[string]*configSchema{
+	"docker": newConfigSchema([]string{
+		"advertise_ipv6_address", "args", "auth_soft_fail", "cap_add", "cap_drop",
+		"command", "cpu_cfs_period", "cpu_hard_limit", "cpuset_cpus",
+		"dns_options", "dns_search_domains", "dns_servers", "entrypoint",
+		"extra_hosts", "force_pull", "hostname", "image", "image_pull_timeout",
+		"interactive", "ipc_mode", "ipv4_address", "ipv6_address", "isolation",
+		"load", "mac_address", "memory_hard_limit", "network_aliases",
+		"network_mode", "pid_mode", "pids_limit", "ports", "privileged",
+		"readonly_rootfs", "runtime", "security_opt", "shm_size", "storage_opt",
+		"tty", "userns_mode", "uts_mode", "volume_driver", "volumes", "work_dir",
+	}, map[string]*configSchema{
+		"auth": newConfigSchema([]string{"email", "password", "server_address", "username"}, nil),
+		"devices": newConfigSchema([]string{
+			"cgroup_permissions", "container_path", "host_path",
+		}, nil),
+		"healthchecks": newConfigSchema([]string{"disable"}, nil),
+		"labels":       openConfigSchema,
+		"logging": newConfigSchema([]string{"driver", "type"}, map[string]*configSchema{
+			"config": openConfigSchema,
+		}),
+		"mount":    dockerMountSchema,
+		"mounts":   dockerMountSchema,
+		"port_map": openConfigSchema,
+		"sysctl":   openConfigSchema,
+		"ulimit":   openConfigSchema,
+	}),
+	"exec": newConfigSchema([]string{
+		"args", "cap_add", "cap_drop", "command", "ipc_mode", "pid_mode",
+		// bitte's exec driver runs a flake instead of a chroot
+		"flake",
+	}, nil),
+	"raw_exec": newConfigSchema([]string{"args", "command"}, nil),
+	"podman": newConfigSchema([]string{
+		"args", "cap_add", "cap_drop", "command", "cpu_cfs_period",
+		"cpu_hard_limit", "devices", "entrypoint", "extra_hosts", "force_pull",
+		"hostname", "image", "image_pull_timeout", "init", "init_path", "labels",
+		"memory_reservation", "memory_swap", "memory_swappiness", "network_mode",
+		"port_map", "ports", "privileged", "readonly_rootfs", "security_opt",
+		"socket", "sysctl", "tmpfs", "tty", "ulimit", "userns", "volumes",
+		"working_dir",
+	}, map[string]*configSchema{
+		"auth": newConfigSchema([]string{"password", "tls_verify", "username"}, nil),
+		"logging": newConfigSchema([]string{"driver"}, map[string]*configSchema{
+			"options": openConfigSchema,
+		}),
+	}),
+	"java": newConfigSchema([]string{
+		"args", "cap_add", "cap_drop", "class", "class_path", "ipc_mode",
+		"jar_path", "jvm_options", "pid_mode",
+	}, nil),
+	// the nix driver of bitte, which builds its root from flakes
+	"nix": newConfigSchema([]string{"args", "command", "flake", "packages"}, nil),
+}
+
+var dockerMountSchema = newConfigSchema([]string{
+	"readonly", "source", "target", "type",
+}, map[string]*configSchema{
+	"bind_options":  newConfigSchema([]string{"propagation"}, nil),
+	"tmpfs_options": newConfigSchema([]string{"mode", "size"}, nil),
+	"volume_options": newConfigSchema([]string{"labels", "no_copy"}, map[string]*configSchema{
+		"driver_config": newConfigSchema([]string{"name", "options"}, nil),
+	}),
+})
+
+// convertConfig writes config as a block called key, laid out according to
+// the schema of driver. Attributes come first, blocks after them. Like in
+// convertIndexed, index tells repeated blocks apart in paths.
+func (c *converter) convertConfig(parent *hclwrite.Body, key string, index int, driver string, schema *configSchema, config map[string]interface{}) {
+	if len(parent.Attributes()) > 0 || len(parent.Blocks()) > 0 {
+		parent.AppendNewline()
+	}
+
+	body := parent.AppendNewBlock(key, []string{}).Body()
+
+	if index >= 0 {
+		c.push(fmt.Sprintf("%s[%d]", key, index))
+	} else {
+		c.push(key)
+	}
+	c.depth++
+	defer func() {
+		c.depth--
+		c.pop()
+	}()
+
+	keys := []string{}
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	blocks := []string{}
+
+	for _, k := range keys {
+		if _, ok := schema.blocks[k]; ok {
+			blocks = append(blocks, k)
+			continue
+		}
+
+		if !schema.open && !schema.attributes[k] {
+			c.warn(fmt.Sprintf("unknown config key for the %s driver", driver), k)
+		}
+
+		v, errs := convertValue(config[k])
+		if len(errs) > 0 {
+			c.fail(errs.within(k))
+			continue
+		}
+
+		c.setCty(body, k, v)
+	}
+
+	for _, k := range blocks {
+		switch value := config[k].(type) {
+		case map[string]interface{}:
+			c.convertConfig(body, k, -1, driver, schema.blocks[k], value)
+		case []interface{}:
+			elems, ok := configBlockList(value)
+			if !ok {
+				c.warn(fmt.Sprintf("the %s driver expects blocks, writing an attribute instead", driver), k)
+				c.setConfigAttribute(body, k, value)
+				continue
+			}
+
+			for i, elem := range elems {
+				c.convertConfig(body, k, i, driver, schema.blocks[k], elem)
+			}
+		default:
+			c.warn(fmt.Sprintf("the %s driver expects a block, writing an attribute instead", driver), k)
+			c.setConfigAttribute(body, k, value)
+		}
+	}
+}
+
+func (c *converter) setConfigAttribute(body *hclwrite.Body, key string, value interface{}) {
+	v, errs := convertValue(value)
+	if len(errs) > 0 {
+		c.fail(errs.within(key))
+		return
+	}
+
+	c.setCty(body, key, v)
+}
+
+// configBlockList returns the elements of list if all of them are objects.
+func configBlockList(list []interface{}) ([]map[string]interface{}, bool) {
+	elems := []map[string]interface{}{}
+	for _, elem := range list {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		elems = append(elems, m)
+	}
+
+	return elems, true
+}
@@ -0,0 +1,89 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriverConfig(t *testing.T) {
+	r := require.New(t)
+
+	// CUE exports blocks as plain objects rather than lists of them
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				Config: map[string]interface{}{
+					"image":   "nginx",
+					"logging": map[string]interface{}{"type": "journald"},
+				},
+			}},
+		}},
+	}
+
+	f, diags, err := any2hcl("job", job, Options{})
+	r.Nil(err)
+	r.Empty(diags)
+	r.Equal(`job "docs" {
+  group "example" {
+    task "server" {
+      driver = "docker"
+
+      config {
+        image = "nginx"
+
+        logging {
+          type = "journald"
+        }
+      }
+    }
+  }
+}`, strings.TrimSpace(string(f.Bytes())))
+	r.Nil(Verify(job, f))
+
+	missing, err := Coverage(job, f)
+	r.Nil(err)
+	r.Empty(missing)
+}
+
+func TestDriverConfigWarnings(t *testing.T) {
+	r := require.New(t)
+
+	_, diags, err := any2hcl("job", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				Config: map[string]interface{}{
+					"image":   "nginx",
+					"imgae":   "nginx",
+					"auth":    "deploy:hunter2",
+					"logging": map[string]interface{}{"typ": "journald"},
+				},
+			}, {
+				Name:   "script",
+				Driver: "raw_exec",
+				Config: map[string]interface{}{"command": "date", "image": "nginx"},
+			}, {
+				Name:   "vm",
+				Driver: "qemu",
+				Config: map[string]interface{}{"image_path": "local/linux.img"},
+			}},
+		}},
+	}, Options{})
+	r.Nil(err)
+	r.Equal(strings.Join([]string{
+		`warning: converter: job["docs"].group["example"].task["server"].config.imgae: unknown config key for the docker driver`,
+		`warning: converter: job["docs"].group["example"].task["server"].config.auth: the docker driver expects a block, writing an attribute instead`,
+		`warning: converter: job["docs"].group["example"].task["server"].config.logging.typ: unknown config key for the docker driver`,
+		`warning: converter: job["docs"].group["example"].task["script"].config.image: unknown config key for the raw_exec driver`,
+	}, "\n"), diags.Error())
+}
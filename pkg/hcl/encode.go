@@ -0,0 +1,961 @@
+// Package hcl renders Nomad jobs as HCL and parses them back.
+package hcl
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Options control how a job is rendered.
+type Options struct {
+	// Heredoc is one of indent (the default), flat or never.
+	Heredoc string
+	// Annotate returns a comment for a labeled block by its path, like
+	// job["web"].group["api"], or an empty string for none.
+	Annotate func(path string) string
+	// ExtractVars lifts values that appear more than once into variables.
+	ExtractVars bool
+	// Variables maps variable names to values that are always lifted.
+	Variables map[string]string
+	// Syntax is hcl2 (the default) or hcl1, for clusters running a Nomad
+	// that can't parse HCL2 yet.
+	Syntax string
+	// NomadVersion is the Nomad the job is for, like 1.1.4. Fields it
+	// doesn't know are left out. If empty, every field of the pinned api is
+	// written.
+	NomadVersion string
+}
+
+const (
+	HeredocIndent = "indent"
+	HeredocFlat   = "flat"
+	HeredocNever  = "never"
+)
+
+const (
+	SyntaxHcl2 = "hcl2"
+	SyntaxHcl1 = "hcl1"
+)
+
+// Render converts job to a `job` block. Values it can't convert are errors,
+// values it has to leave out are returned as warnings.
+func Render(job *api.Job, opts Options) (*hclwrite.File, diag.Diagnostics, error) {
+	return any2hcl("job", job, opts)
+}
+
+// any2hcl converts any to a block called key.
+func any2hcl(key string, any interface{}, opts Options) (*hclwrite.File, diag.Diagnostics, error) {
+	switch opts.Heredoc {
+	case "":
+		opts.Heredoc = HeredocIndent
+	case HeredocIndent, HeredocFlat, HeredocNever:
+	default:
+		return nil, nil, fmt.Errorf("Unknown heredoc mode %q, use one of %s, %s or %s", opts.Heredoc, HeredocIndent, HeredocFlat, HeredocNever)
+	}
+
+	switch opts.Syntax {
+	case "", SyntaxHcl2:
+	case SyntaxHcl1:
+		if opts.ExtractVars || len(opts.Variables) > 0 {
+			return nil, nil, fmt.Errorf("Variables need %s, %s has none", SyntaxHcl2, SyntaxHcl1)
+		}
+
+		// older HCL1 parsers don't know indented heredocs
+		if opts.Heredoc == HeredocIndent {
+			opts.Heredoc = HeredocFlat
+		}
+	default:
+		return nil, nil, fmt.Errorf("Unknown syntax %q, use one of %s or %s", opts.Syntax, SyntaxHcl2, SyntaxHcl1)
+	}
+
+	var target *nomadVersion
+	if opts.NomadVersion != "" {
+		v, err := parseNomadVersion(opts.NomadVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		target = &v
+	}
+
+	rv := reflect.ValueOf(any)
+	if rv.Kind() == reflect.Ptr {
+		any = rv.Elem().Interface()
+	}
+
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	c := &converter{heredoc: opts.Heredoc, annotate: opts.Annotate, hcl1: opts.Syntax == SyntaxHcl1, target: target}
+
+	if opts.ExtractVars || len(opts.Variables) > 0 {
+		// a first pass finds the values worth lifting, its output is thrown away
+		counter := &converter{heredoc: opts.Heredoc, annotate: opts.Annotate, target: target, occurrences: &occurrences{}}
+		counter.convert(hclwrite.NewEmptyFile().Body(), key, any)
+
+		vars, warnings := counter.occurrences.variables(opts)
+		c.warnings = append(c.warnings, warnings...)
+		c.writeVariables(body, vars)
+
+		c.variables = map[string]string{}
+		for _, v := range vars {
+			c.variables[v.key] = v.name
+		}
+	}
+
+	c.convert(body, key, any)
+
+	if len(c.errors) > 0 {
+		return f, c.warnings, c.errors
+	}
+
+	return f, c.warnings, nil
+}
+
+// ConversionError describes a value that couldn't be converted to HCL.
+type ConversionError struct {
+	// Path through the job tree, like job["web"].group["api"].task["server"].config.port
+	Path   []string
+	Type   reflect.Type
+	Reason string
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("%s: %s %s", formatPath(e.Path), e.Reason, e.Type)
+}
+
+// ConversionErrors holds every ConversionError found while converting a job.
+type ConversionErrors []*ConversionError
+
+func (e ConversionErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// within prefixes the path of every error with the given segments.
+func (e ConversionErrors) within(segments ...string) ConversionErrors {
+	for _, err := range e {
+		err.Path = append(append([]string{}, segments...), err.Path...)
+	}
+
+	return e
+}
+
+func unsupportedType(v interface{}) ConversionErrors {
+	return ConversionErrors{{Type: reflect.TypeOf(v), Reason: "unsupported type"}}
+}
+
+// isStructValue is true for structs and lists of structs that are tagged as
+// attributes, like `mount_options` in api.CSIVolume. Those can only be
+// written as blocks.
+func isStructValue(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return isStructType(t)
+}
+
+func formatPath(path []string) string {
+	sb := &strings.Builder{}
+	for i, segment := range path {
+		if i > 0 && !strings.HasPrefix(segment, "[") {
+			sb.WriteString(".")
+		}
+		sb.WriteString(segment)
+	}
+
+	return sb.String()
+}
+
+type structField struct {
+	Name     string
+	Block    bool
+	Optional bool
+	Label    bool
+	Value    interface{}
+}
+
+func (s *structField) Cty() (cty.Value, ConversionErrors) {
+	switch v := s.Value.(type) {
+	case nil:
+		return cty.NilVal, nil
+	case string:
+		if v == "" && s.Optional {
+			return cty.NilVal, nil
+		} else {
+			return cty.StringVal(v), nil
+		}
+	case *string:
+		if v == nil {
+			return cty.NilVal, nil
+		} else {
+			return cty.StringVal(*v), nil
+		}
+	case bool:
+		if !v && s.Optional {
+			return cty.NilVal, nil
+		} else {
+			return cty.BoolVal(v), nil
+		}
+	case *bool:
+		if v == nil {
+			return cty.NilVal, nil
+		} else {
+			return cty.BoolVal(*v), nil
+		}
+	case time.Duration:
+		if v == 0 && s.Optional {
+			return cty.NilVal, nil
+		} else {
+			return cty.StringVal(v.String()), nil
+		}
+	case *time.Duration:
+		if v == nil {
+			return cty.NilVal, nil
+		} else {
+			return cty.StringVal(v.String()), nil
+		}
+	case []string:
+		if len(v) == 0 {
+			if s.Optional {
+				return cty.NilVal, nil
+			} else {
+				return cty.ListValEmpty(cty.String), nil
+			}
+		} else {
+			converted := []cty.Value{}
+			for _, value := range v {
+				converted = append(converted, cty.StringVal(value))
+			}
+			return cty.ListVal(converted), nil
+		}
+	default:
+		rv := reflect.ValueOf(v)
+
+		switch {
+		case isNumberKind(rv.Kind()):
+			if rv.IsZero() && s.Optional {
+				return cty.NilVal, nil
+			}
+			return numberVal(rv)
+		case rv.Kind() == reflect.Ptr && isNumberKind(rv.Type().Elem().Kind()):
+			if rv.IsNil() {
+				return cty.NilVal, nil
+			}
+			return numberVal(rv.Elem())
+		case rv.Kind() == reflect.Slice && isNumberKind(rv.Type().Elem().Kind()):
+			if rv.Len() == 0 {
+				if s.Optional {
+					return cty.NilVal, nil
+				}
+				return cty.ListValEmpty(cty.Number), nil
+			}
+			return numberList(rv)
+		case rv.Kind() == reflect.String:
+			// named string types like api.CSIPluginType
+			if rv.Len() == 0 && s.Optional {
+				return cty.NilVal, nil
+			}
+			return cty.StringVal(rv.String()), nil
+		}
+
+		return cty.NilVal, unsupportedType(v)
+	}
+}
+
+// numberVal converts any integer or float kind to a number. Floats without a
+// fractional part, like the ones encoding/json produces for task configs,
+// are kept as integers.
+func numberVal(rv reflect.Value) (cty.Value, ConversionErrors) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cty.NumberIntVal(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cty.NumberUIntVal(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return cty.NilVal, ConversionErrors{{Type: rv.Type(), Reason: fmt.Sprintf("can't represent %v as", f)}}
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return cty.NumberIntVal(int64(f)), nil
+		}
+		return cty.NumberFloatVal(f), nil
+	}
+
+	return cty.NilVal, unsupportedType(rv.Interface())
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func numberList(rv reflect.Value) (cty.Value, ConversionErrors) {
+	list := []cty.Value{}
+	errs := ConversionErrors{}
+
+	for i := 0; i < rv.Len(); i++ {
+		n, elemErrs := numberVal(rv.Index(i))
+		if len(elemErrs) > 0 {
+			errs = append(errs, elemErrs.within(fmt.Sprintf("[%d]", i))...)
+			continue
+		}
+		list = append(list, n)
+	}
+
+	if len(errs) > 0 {
+		return cty.NilVal, errs
+	}
+
+	return cty.ListVal(list), nil
+}
+
+// parseHclTag splits an `hcl` struct tag like `name,block` into its parts.
+func parseHclTag(tag string) (name string, block, optional, label bool, err error) {
+	for j, elem := range strings.Split(tag, ",") {
+		if j == 0 {
+			name = elem
+		} else if elem == "block" {
+			block = true
+		} else if elem == "optional" {
+			optional = true
+		} else if elem == "label" {
+			label = true
+		} else {
+			return name, block, optional, label, fmt.Errorf("Unknown hcl tag: %s", elem)
+		}
+	}
+
+	return
+}
+
+// converter turns structs with `hcl` tags into HCL blocks, collecting every
+// error it finds along the way instead of stopping at the first one.
+type converter struct {
+	path     []string
+	errors   ConversionErrors
+	warnings diag.Diagnostics
+	heredoc  string
+	annotate func(path string) string
+	// depth is the number of blocks around the current body
+	depth int
+	// hcl1 writes HCL1 instead of HCL2
+	hcl1 bool
+	// target is the Nomad version to write for, nil for any
+	target *nomadVersion
+	// occurrences counts attribute values when looking for variables.
+	occurrences *occurrences
+	// variables maps the key of a lifted value to its variable name.
+	variables map[string]string
+}
+
+func (c *converter) fail(errs ConversionErrors) {
+	c.errors = append(c.errors, errs.within(c.path...)...)
+}
+
+// warn records a warning for the current path, extended by segments.
+func (c *converter) warn(message string, segments ...string) {
+	c.warnings = append(c.warnings, &diag.Diagnostic{
+		Severity: diag.SeverityWarning,
+		Source:   "converter",
+		Path:     formatPath(append(append([]string{}, c.path...), segments...)),
+		Message:  message,
+	})
+}
+
+func (c *converter) push(segment string) {
+	c.path = append(c.path, segment)
+}
+
+func (c *converter) pop() {
+	c.path = c.path[:len(c.path)-1]
+}
+
+func (c *converter) convert(parent *hclwrite.Body, key string, obj interface{}) {
+	c.convertIndexed(parent, key, -1, obj)
+}
+
+// convertIndexed is convert for elements of a slice, index is only used to
+// tell unlabeled blocks apart in error paths.
+func (c *converter) convertIndexed(parent *hclwrite.Body, key string, index int, obj interface{}) {
+	objType := reflect.TypeOf(obj)
+	objValue := reflect.ValueOf(obj)
+
+	if objValue.Kind() == reflect.Ptr {
+		objValue = objValue.Elem()
+	}
+
+	// skip everything without value
+	switch objValue.Kind() {
+	case reflect.Invalid:
+		return
+	case reflect.Struct:
+		if obj == nil {
+			return
+		}
+	case reflect.Array, reflect.Slice, reflect.Map:
+		if objValue.Len() == 0 {
+			return
+		}
+	}
+
+	switch objValue.Kind() {
+	case reflect.Map:
+		if len(parent.Attributes()) > 0 || len(parent.Blocks()) > 0 {
+			parent.AppendNewline()
+		}
+
+		if objType.Key().Kind() != reflect.String {
+			c.fail(ConversionErrors{{Path: []string{key}, Type: objType, Reason: "unsupported map key in"}})
+			return
+		}
+
+		keys := []string{}
+		for _, keyValue := range objValue.MapKeys() {
+			keys = append(keys, keyValue.String())
+		}
+
+		sort.Strings(keys)
+
+		// maps of structs become one labeled block per entry, like
+		// `volume "certs" { ... }`. Anything else is a block of attributes,
+		// like `meta { ... }`.
+		if isStructType(objType.Elem()) {
+			for i, mapKey := range keys {
+				mapValue := objValue.MapIndex(reflect.ValueOf(mapKey).Convert(objType.Key()))
+				if mapValue.Kind() == reflect.Ptr {
+					if mapValue.IsNil() {
+						c.warn("left out nil block", fmt.Sprintf("%s[%q]", key, mapKey))
+						continue
+					}
+					mapValue = mapValue.Elem()
+				}
+
+				if i > 0 {
+					parent.AppendNewline()
+				}
+
+				body := parent.AppendNewBlock(key, []string{mapKey}).Body()
+				c.push(fmt.Sprintf("%s[%q]", key, mapKey))
+				c.depth++
+				c.convert(body, "", mapValue.Interface())
+				c.depth--
+				c.pop()
+			}
+		} else {
+			body := parent.AppendNewBlock(key, []string{}).Body()
+
+			c.push(key)
+			c.depth++
+			for _, mapKey := range keys {
+				mapValue := objValue.MapIndex(reflect.ValueOf(mapKey).Convert(objType.Key()))
+
+				v, errs := convertValue(mapValue.Interface())
+				if len(errs) > 0 {
+					c.fail(errs.within(mapKey))
+					continue
+				}
+
+				c.setCty(body, mapKey, v)
+			}
+			c.depth--
+			c.pop()
+		}
+
+	case reflect.Struct:
+		if len(parent.Attributes()) > 0 || len(parent.Blocks()) > 0 {
+			parent.AppendNewline()
+		}
+
+		structFields := []*structField{}
+		tagErrors := ConversionErrors{}
+		var blockLabel string
+		hasLabel := false
+
+		for i := 0; i < objType.NumField(); i++ {
+			field := objType.Field(i)
+			tag := field.Tag.Get("hcl")
+			if tag == "" {
+				continue
+			}
+
+			name, block, optional, label, err := parseHclTag(tag)
+			if err != nil {
+				tagErrors = append(tagErrors, &ConversionError{Path: []string{field.Name}, Type: objType, Reason: fmt.Sprintf("%s on", err)})
+				continue
+			}
+
+			fieldValue := objValue.Field(i)
+
+			if label {
+				hasLabel = true
+				if fieldValue.Kind() == reflect.Ptr {
+					blockLabel = fieldValue.Elem().String()
+				} else {
+					blockLabel = fieldValue.String()
+				}
+			} else if name != "" {
+				var structValue interface{}
+
+				// attributes keep their pointer, so that an explicit false or 0
+				// isn't mistaken for an unset optional value.
+				if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() && (block || isStructValue(fieldValue.Interface())) {
+					structValue = fieldValue.Elem().Interface()
+				} else {
+					structValue = fieldValue.Interface()
+				}
+
+				structFields = append(structFields, &structField{name, block, optional, label, structValue})
+			}
+
+			// why is job special?
+			if key == "job" && name == "name" && blockLabel == "" {
+				hasLabel = true
+				if fieldValue.Kind() == reflect.Ptr {
+					blockLabel = fieldValue.Elem().String()
+				} else {
+					blockLabel = fieldValue.String()
+				}
+			}
+		}
+
+		var body *hclwrite.Body
+
+		if key == "" {
+			body = parent
+		} else {
+			switch {
+			case blockLabel != "":
+				c.push(fmt.Sprintf("%s[%q]", key, blockLabel))
+			case index >= 0:
+				c.push(fmt.Sprintf("%s[%d]", key, index))
+			default:
+				c.push(key)
+			}
+			c.depth++
+			defer func() {
+				c.depth--
+				c.pop()
+			}()
+
+			if blockLabel == "" {
+				body = parent.AppendNewBlock(key, []string{}).Body()
+			} else {
+				c.comment(parent)
+				body = parent.AppendNewBlock(key, []string{blockLabel}).Body()
+			}
+		}
+
+		if len(tagErrors) > 0 {
+			c.fail(tagErrors)
+		}
+
+		if hasLabel && blockLabel == "" && key != "" {
+			c.warn("block has an empty name, Nomad will reject it")
+		}
+
+		// tasks and sidecar tasks write their config the way their driver
+		// expects it
+		driver := ""
+		for _, field := range structFields {
+			if name, ok := field.Value.(string); ok && field.Name == "driver" {
+				driver = name
+			}
+		}
+
+		for _, field := range structFields {
+			if !c.versionedField(objType, field) {
+				continue
+			}
+
+			config, isConfig := field.Value.(map[string]interface{})
+
+			switch {
+			case isConfig && field.Name == "config" && driverSchemas[driver] != nil:
+				if len(config) > 0 {
+					c.convertConfig(body, field.Name, -1, driver, driverSchemas[driver], config)
+				}
+			case field.Block, isStructValue(field.Value):
+				// optional blocks are simply left out when they're empty
+				c.convert(body, field.Name, field.Value)
+			default:
+				if key == "job" && field.Name == "name" {
+					continue
+				}
+				// required attributes are written even if they're zero
+				v, errs := field.Cty()
+				if len(errs) > 0 {
+					c.fail(errs.within(field.Name))
+				} else if v != cty.NilVal {
+					c.setCty(body, field.Name, v)
+				}
+			}
+		}
+	case reflect.Slice:
+		// body := parent.AppendNewBlock(key, []string{}).Body()
+		for i := 0; i < objValue.Len(); i++ {
+			elem := objValue.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if !elem.IsValid() {
+				c.warn("left out nil block", fmt.Sprintf("%s[%d]", key, i))
+				continue
+			}
+			c.convertIndexed(parent, key, i, elem.Interface())
+		}
+	default:
+		c.fail(ConversionErrors{{Path: []string{key}, Type: objType, Reason: "unsupported block type"}})
+	}
+}
+
+// comment writes the annotation for the labeled block at the current path,
+// if there is one.
+func (c *converter) comment(parent *hclwrite.Body) {
+	if c.annotate == nil {
+		return
+	}
+
+	if comment := c.annotate(formatPath(c.path)); comment != "" {
+		parent.AppendUnstructuredTokens(hclwrite.Tokens{
+			{Type: hclsyntax.TokenComment, Bytes: []byte("# " + comment + "\n"), SpacesBefore: 0},
+		})
+	}
+}
+
+func (c *converter) setCty(body *hclwrite.Body, key string, value cty.Value) {
+	if c.setVariable(body, key, value) {
+		return
+	}
+
+	if c.hcl1 && value.Type() != cty.String {
+		c.setHcl1(body, key, value)
+		return
+	}
+
+	if value.Type() != cty.String {
+		body.SetAttributeValue(key, value)
+		return
+	}
+
+	s := value.AsString()
+
+	if tokens := c.heredocTokens(s); tokens != nil {
+		body.SetAttributeRaw(key, tokens)
+	} else {
+		body.SetAttributeRaw(key, hclwrite.Tokens{
+			{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`), SpacesBefore: 0},
+			{Type: hclsyntax.TokenStringLit, Bytes: []byte(escapeQuoted(s, !c.hcl1)), SpacesBefore: 0},
+			{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`), SpacesBefore: 0},
+		})
+	}
+}
+
+// heredocTokens renders s as a heredoc, indented to the current depth if
+// possible. It returns nil if s has to be quoted instead.
+func (c *converter) heredocTokens(s string) hclwrite.Tokens {
+	if c.heredoc == HeredocNever || !heredocSafe(s) {
+		return nil
+	}
+
+	delimiter := heredocDelimiter(s)
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	content := &strings.Builder{}
+
+	if c.heredoc == HeredocIndent && heredocIndentable(lines) {
+		indent := strings.Repeat("  ", c.depth)
+
+		for _, line := range lines {
+			content.WriteString("\n")
+			// whitespace-only lines are left alone by HCL when it strips the
+			// indentation, so they can't get any either.
+			if strings.TrimSpace(line) != "" {
+				content.WriteString(indent + "  ")
+			}
+			content.WriteString(escapeHeredoc(line, !c.hcl1))
+		}
+
+		content.WriteString("\n" + indent)
+
+		return hclwrite.Tokens{
+			{Type: hclsyntax.TokenOHeredoc, Bytes: []byte("<<-" + delimiter), SpacesBefore: 0},
+			{Type: hclsyntax.TokenStringLit, Bytes: []byte(content.String()), SpacesBefore: 0},
+			{Type: hclsyntax.TokenCHeredoc, Bytes: []byte(delimiter), SpacesBefore: 0},
+		}
+	}
+
+	for _, line := range lines {
+		content.WriteString("\n" + escapeHeredoc(line, !c.hcl1))
+	}
+
+	content.WriteString("\n")
+
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenOHeredoc, Bytes: []byte("<<" + delimiter), SpacesBefore: 0},
+		{Type: hclsyntax.TokenStringLit, Bytes: []byte(content.String()), SpacesBefore: 0},
+		{Type: hclsyntax.TokenCHeredoc, Bytes: []byte(delimiter), SpacesBefore: 0},
+	}
+}
+
+// heredocSafe is true for multi-line strings that a heredoc can reproduce
+// exactly. Heredocs always end in a newline and don't support escape
+// sequences, so strings without a final newline or with other control
+// characters than newlines and tabs have to be quoted.
+func heredocSafe(s string) bool {
+	if !strings.Contains(s, "\n") || !strings.HasSuffix(s, "\n") {
+		return false
+	}
+
+	for _, r := range s {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// heredocIndentable is true if at least one line isn't indented already.
+// HCL strips the smallest indentation of all lines from an indented heredoc,
+// which would otherwise remove some of the original indentation as well.
+func heredocIndentable(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// heredocDelimiter picks a delimiter that doesn't appear as a line in s.
+func heredocDelimiter(s string) string {
+	lines := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+
+	for _, candidate := range []string{"EOT", "EOF", "END"} {
+		if !lines[candidate] {
+			return candidate
+		}
+	}
+
+	for i := 1; ; i++ {
+		if candidate := fmt.Sprintf("EOT%d", i); !lines[candidate] {
+			return candidate
+		}
+	}
+}
+
+// escapeQuoted escapes s for use between double quotes in HCL. Template
+// sequences are escaped if templates is set, HCL1 has none.
+func escapeQuoted(s string, templates bool) string {
+	sb := &strings.Builder{}
+
+	for i, r := range s {
+		switch r {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '$', '%':
+			escapeTemplateIntroducer(sb, r, s[i+1:], templates)
+		default:
+			if !unicode.IsPrint(r) {
+				if r < 0x10000 {
+					fmt.Fprintf(sb, `\u%04x`, r)
+				} else {
+					fmt.Fprintf(sb, `\U%08x`, r)
+				}
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// escapeHeredoc escapes s for use in a heredoc, where only template
+// sequences have a special meaning.
+func escapeHeredoc(s string, templates bool) string {
+	sb := &strings.Builder{}
+
+	for i, r := range s {
+		switch r {
+		case '$', '%':
+			escapeTemplateIntroducer(sb, r, s[i+1:], templates)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// escapeTemplateIntroducer doubles $ and % in front of a { so Nomad doesn't
+// interpolate what was meant to be a literal ${...} or %{...}.
+func escapeTemplateIntroducer(sb *strings.Builder, r rune, rest string, templates bool) {
+	sb.WriteRune(r)
+	if templates && strings.HasPrefix(rest, "{") {
+		sb.WriteRune(r)
+	}
+}
+
+func convertValue(value interface{}) (cv cty.Value, errs ConversionErrors) {
+	switch v := value.(type) {
+	case string:
+		return cty.StringVal(v), nil
+	case *string:
+		if v != nil {
+			return cty.StringVal(*v), nil
+		}
+	case *bool:
+		if v != nil {
+			return cty.BoolVal(*v), nil
+		}
+	case nil:
+		return cty.NilVal, nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case time.Duration:
+		return cty.StringVal(v.String()), nil
+	case *time.Duration:
+		if v != nil {
+			return cty.StringVal(v.String()), nil
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return cty.ListValEmpty(cty.String), nil
+		} else {
+			list := []cty.Value{}
+			for i, value := range v {
+				converted, elemErrs := convertValue(value)
+				if len(elemErrs) > 0 {
+					errs = append(errs, elemErrs.within(fmt.Sprintf("[%d]", i))...)
+					continue
+				}
+				list = append(list, converted)
+			}
+			if len(errs) > 0 {
+				return cv, errs
+			}
+			// a tuple, because CUE lists can mix strings, numbers and objects
+			return cty.TupleVal(list), nil
+		}
+	case []string:
+		if len(v) != 0 {
+			converted := []cty.Value{}
+			for _, value := range v {
+				converted = append(converted, cty.StringVal(value))
+			}
+			return cty.ListVal(converted), nil
+		}
+	case map[string]string:
+		if len(v) != 0 {
+			converted := map[string]cty.Value{}
+			for mk, mv := range v {
+				converted[mk] = cty.StringVal(mv)
+			}
+			return cty.MapVal(converted), nil
+		}
+	case map[string][]string:
+		if len(v) != 0 {
+			keys := []string{}
+			for mk := range v {
+				keys = append(keys, mk)
+			}
+			sort.Strings(keys)
+
+			convertedMap := map[string]cty.Value{}
+			for _, mk := range keys {
+				convertedList, listErrs := convertValue(v[mk])
+				if len(listErrs) > 0 {
+					errs = append(errs, listErrs.within(mk)...)
+					continue
+				}
+				convertedMap[mk] = convertedList
+			}
+			if len(errs) > 0 {
+				return cv, errs
+			}
+			return cty.MapVal(convertedMap), nil
+		}
+	case map[string]interface{}:
+		if len(v) != 0 {
+			keys := []string{}
+			for mk := range v {
+				keys = append(keys, mk)
+			}
+			sort.Strings(keys)
+
+			convertedMap := map[string]cty.Value{}
+			for _, mk := range keys {
+				converted, elemErrs := convertValue(v[mk])
+				if len(elemErrs) > 0 {
+					errs = append(errs, elemErrs.within(mk)...)
+					continue
+				}
+				convertedMap[mk] = converted
+			}
+			if len(errs) > 0 {
+				return cv, errs
+			}
+			return cty.ObjectVal(convertedMap), nil
+		}
+	default:
+		rv := reflect.ValueOf(v)
+
+		switch {
+		case isNumberKind(rv.Kind()):
+			return numberVal(rv)
+		case rv.Kind() == reflect.Ptr && isNumberKind(rv.Type().Elem().Kind()):
+			if !rv.IsNil() {
+				return numberVal(rv.Elem())
+			}
+		case rv.Kind() == reflect.Slice && isNumberKind(rv.Type().Elem().Kind()):
+			if rv.Len() != 0 {
+				return numberList(rv)
+			}
+		case rv.Kind() == reflect.String:
+			return cty.StringVal(rv.String()), nil
+		default:
+			return cv, unsupportedType(v)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,922 @@
+package hcl
+
+import (
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob2Hcl(t *testing.T) {
+	r := require.New(t)
+
+	compare(r, "fixtures/1.hcl", &api.Job{
+		Name:        ptrStr("mysql"),
+		Region:      ptrStr("eu"),
+		Namespace:   ptrStr("test"),
+		ID:          ptrStr("123"),
+		Type:        ptrStr("batch"),
+		Priority:    ptrInt(50),
+		AllAtOnce:   ptrBool(true),
+		Datacenters: []string{"a", "b"},
+		Constraints: []*api.Constraint{
+			{
+				LTarget: "left",
+				RTarget: "right",
+				Operand: "op",
+			},
+		},
+		Affinities: []*api.Affinity{
+			{
+				LTarget: "left",
+				RTarget: "right",
+				Operand: "op",
+				Weight:  ptrInt8(10),
+			},
+		},
+		TaskGroups: []*api.TaskGroup{
+			{
+				Name: ptrStr("mysqld"),
+				RestartPolicy: &api.RestartPolicy{
+					Attempts: ptrInt(3),
+					Delay:    ptrDuration(10 * time.Second),
+					Interval: ptrDuration(10 * time.Minute),
+					Mode:     ptrStr("fail"),
+				},
+				Tasks: []*api.Task{{
+					Name: "server",
+					Services: []*api.Service{{
+						Tags:      []string{"leader", "mysql"},
+						PortLabel: "db",
+						Checks: []api.ServiceCheck{
+							{Type: "tcp", PortLabel: "db", Interval: 10 * time.Second, Timeout: 2 * time.Second},
+							{Type: "script", Name: "check_table",
+								Command:  "/usr/local/bin/check_mysql_table_status",
+								Args:     []string{"--verbose"},
+								Interval: 60 * time.Second, Timeout: 5 * time.Second,
+								CheckRestart: &api.CheckRestart{
+									Limit:          3,
+									Grace:          ptrDuration(90 * time.Second),
+									IgnoreWarnings: false,
+								},
+							},
+						},
+					}},
+				}},
+			},
+		},
+		Meta: map[string]string{"hi": "there"},
+	})
+
+	compare(r, "fixtures/2.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{
+			{
+				Name: ptrStr("example"),
+				Tasks: []*api.Task{{
+					Name: "server",
+					Artifacts: []*api.TaskArtifact{
+						{
+							GetterSource: ptrStr("https://example.com/file.tar.gz"),
+							RelativeDest: ptrStr("local/some-directory"),
+							GetterOptions: map[string]string{
+								"checksum": "md5:df6a4178aec9fbdc1d6d7e3634d1bc33",
+								"depth":    "1",
+							},
+							GetterHeaders: map[string]string{
+								"User-Agent":    "nomad-[${NOMAD_JOB_ID}]-[${NOMAD_GROUP_NAME}]-[${NOMAD_TASK_NAME}]",
+								"X-Nomad-Alloc": "${NOMAD_ALLOC_ID}",
+							},
+						},
+					},
+				}},
+			},
+		},
+	})
+
+	compare(r, "fixtures/3.hcl", &api.Job{
+		Name: ptrStr("docs"),
+
+		Affinities: []*api.Affinity{{
+			LTarget: "${node.datacenter}",
+			RTarget: "us-west1",
+			Weight:  ptrInt8(100),
+		}},
+
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+
+			Affinities: []*api.Affinity{{
+				LTarget: "${meta.rack}",
+				RTarget: "r1",
+				Weight:  ptrInt8(50),
+			}},
+
+			Tasks: []*api.Task{{
+				Name: "server",
+
+				Affinities: []*api.Affinity{{
+					LTarget: "${meta.my_custom_value}",
+					RTarget: "3",
+					Operand: ">",
+					Weight:  ptrInt8(50),
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/4.hcl", &api.Job{
+		Name:        ptrStr("countdash"),
+		Datacenters: []string{"dc1"},
+
+		TaskGroups: []*api.TaskGroup{
+			{
+				Name: ptrStr("api"),
+
+				Networks: []*api.NetworkResource{{
+					Mode: "bridge",
+				}},
+
+				Services: []*api.Service{{
+					Name:      "count-api",
+					PortLabel: "9001",
+
+					Connect: &api.ConsulConnect{
+						SidecarService: &api.ConsulSidecarService{},
+					},
+
+					Checks: []api.ServiceCheck{{
+						Expose:   true,
+						Type:     "http",
+						Name:     "api-health",
+						Path:     "/health",
+						Interval: 10 * time.Second,
+						Timeout:  3 * time.Second,
+					}},
+				}},
+
+				Tasks: []*api.Task{{
+					Name:   "web",
+					Driver: "docker",
+					Config: map[string]interface{}{
+						"image": "hashicorpnomad/counter-api:v3",
+					},
+				}},
+			},
+
+			{
+				Name: ptrStr("dashboard"),
+
+				Networks: []*api.NetworkResource{{
+					Mode: "bridge",
+					DynamicPorts: []api.Port{{
+						Label: "http",
+						Value: 9002,
+						To:    9002,
+					}},
+				}},
+
+				Services: []*api.Service{{
+					Name:      "count-dashboard",
+					PortLabel: "9002",
+
+					Connect: &api.ConsulConnect{
+						SidecarService: &api.ConsulSidecarService{
+							Proxy: &api.ConsulProxy{
+								Upstreams: []*api.ConsulUpstream{{
+									DestinationName: "count-api",
+									LocalBindPort:   8080,
+								}},
+							},
+						},
+					},
+				}},
+
+				Tasks: []*api.Task{{
+					Name:   "dashboard",
+					Driver: "docker",
+
+					Config: map[string]interface{}{
+						"image": "hashicorpnomad/counter-dashboard:v3",
+					},
+
+					Env: map[string]string{
+						"COUNTING_SERVICE_URL": "http://${NOMAD_UPSTREAM_ADDR_count_api}",
+					},
+				}},
+			},
+		},
+	})
+
+	compare(r, "fixtures/5.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		Constraints: []*api.Constraint{{
+			LTarget: "${attr.kernel.name}",
+			RTarget: "linux",
+		}},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Constraints: []*api.Constraint{{
+				Operand: "distinct_hosts",
+				RTarget: "true",
+			}},
+			Tasks: []*api.Task{{
+				Name: "server",
+				Constraints: []*api.Constraint{{
+					LTarget: "${meta.my_custom_value}",
+					RTarget: "3",
+					Operand: ">",
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/6.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name: "server",
+				Resources: &api.Resources{
+					Devices: []*api.RequestedDevice{{
+						Name:  "nvidia/gpu",
+						Count: ptrUInt64(2),
+						Constraints: []*api.Constraint{{
+							LTarget: "${device.attr.memory}",
+							Operand: ">=",
+							RTarget: "2 GiB",
+						}},
+						Affinities: []*api.Affinity{{
+							LTarget: "${device.attr.memory}",
+							RTarget: "4 GiB",
+							Operand: ">=",
+							Weight:  ptrInt8(75),
+						}},
+					}},
+				},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/7.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:            "server",
+				DispatchPayload: &api.DispatchPayloadConfig{File: "config.json"},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/8.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			EphemeralDisk: &api.EphemeralDisk{
+				Migrate: ptrBool(true),
+				SizeMB:  ptrInt(500),
+				Sticky:  ptrBool(true),
+			},
+		}},
+	})
+
+	compare(r, "fixtures/9.hcl", &api.Job{
+		Name:        ptrStr("ingress-demo"),
+		Datacenters: []string{"dc1"},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("ingress-group"),
+			Networks: []*api.NetworkResource{{
+				Mode: "bridge",
+				DynamicPorts: []api.Port{{
+					Label: "inbound",
+					Value: 8080,
+					To:    8080,
+				}},
+			}},
+			Services: []*api.Service{{
+				Name:      "my-ingress-service",
+				PortLabel: "8080",
+				Connect: &api.ConsulConnect{
+					Gateway: &api.ConsulGateway{
+						Proxy: &api.ConsulGatewayProxy{
+							EnvoyGatewayNoDefaultBind: true,
+							// TODO: there's not a whole lot of type information to handle this...
+							EnvoyGatewayBindAddresses: map[string]*api.ConsulGatewayBindAddress{
+								"address": {Name: "address", Address: "0.0.0.0", Port: 12},
+							},
+						},
+						Ingress: &api.ConsulIngressConfigEntry{
+							Listeners: []*api.ConsulIngressListener{{
+								Port:     8080,
+								Protocol: "tcp",
+								Services: []*api.ConsulIngressService{{
+									Name: "uuid-api",
+								}},
+							}},
+						},
+					},
+				},
+			}},
+		},
+			{
+				Name: ptrStr("generator"),
+				Networks: []*api.NetworkResource{{
+					Mode: "host",
+					DynamicPorts: []api.Port{{
+						Label: "api",
+					}},
+				}},
+
+				Services: []*api.Service{{
+					Name:      "uuid-api",
+					PortLabel: "api",
+					Connect: &api.ConsulConnect{
+						Native: true,
+					},
+				}},
+
+				Tasks: []*api.Task{{
+					Name:   "generate",
+					Driver: "docker",
+					Config: map[string]interface{}{
+						"image":        "hashicorpnomad/uuid-api:v5",
+						"network_mode": "host",
+					},
+					Env: map[string]string{
+						"BIND": "0.0.0.0",
+						"PORT": "${NOMAD_PORT_api}",
+					},
+				}},
+			}},
+	})
+
+	compare(r, "fixtures/10.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Volumes: map[string]*api.VolumeRequest{
+				"certs": {
+					Type:     "host",
+					ReadOnly: true,
+					Source:   "ca-certificates",
+				}},
+			Tasks: []*api.Task{{
+				Name: "example",
+				VolumeMounts: []*api.VolumeMount{{
+					Volume:      ptrStr("certs"),
+					Destination: ptrStr("/etc/ssl/certs"),
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/11.hcl", &api.Job{
+		Name: ptrStr("test"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("test"),
+			Tasks: []*api.Task{{
+				Name: "test",
+				Templates: []*api.Template{{
+					SourcePath:   ptrStr("foo"),
+					DestPath:     ptrStr("bar"),
+					EmbeddedTmpl: ptrStr(`Something with "quotes" is fun`),
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/12.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		Spreads: []*api.Spread{{
+			Attribute: "${node.datacenter}",
+			Weight:    ptrInt8(100),
+			SpreadTarget: []*api.SpreadTarget{
+				{Value: "us-east1", Percent: 60},
+				{Value: "us-west1", Percent: 40},
+			},
+		}},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Scaling: &api.ScalingPolicy{
+				Min:     ptrInt64(1),
+				Max:     ptrInt64(10),
+				Enabled: ptrBool(true),
+				Policy: map[string]interface{}{
+					"cooldown":            "1m",
+					"evaluation_interval": "30s",
+					"target":              0.5,
+				},
+			},
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				// numbers from CUE JSON always arrive as float64
+				Config: map[string]interface{}{
+					"image":             "nginx",
+					"ports":             []interface{}{"http", float64(8080)},
+					"cpu_hard_limit":    true,
+					"memory_hard_limit": float64(512),
+				},
+				Resources: &api.Resources{
+					Devices: []*api.RequestedDevice{{
+						Name:  "nvidia/gpu",
+						Count: ptrUInt64(math.MaxUint64),
+					}},
+				},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/13.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Volumes: map[string]*api.VolumeRequest{
+				"data": {
+					Name:           "data",
+					Type:           "csi",
+					Source:         "mysql-data",
+					AccessMode:     "single-node-writer",
+					AttachmentMode: "file-system",
+					PerAlloc:       true,
+					MountOptions: &api.CSIMountOptions{
+						FSType:     "ext4",
+						MountFlags: []string{"noatime"},
+					},
+				},
+				"certs": {
+					Name:     "certs",
+					Type:     "host",
+					Source:   "ca-certificates",
+					ReadOnly: true,
+				},
+			},
+			Services: []*api.Service{{
+				Name:      "web",
+				PortLabel: "http",
+				Checks: []api.ServiceCheck{{
+					Type:     "http",
+					Path:     "/health",
+					Interval: 10 * time.Second,
+					Timeout:  2 * time.Second,
+					Header: map[string][]string{
+						"Authorization":   {"Basic ZWxhc3RpYzpjaGFuZ2VtZQ=="},
+						"X-Forwarded-For": {"a", "b"},
+					},
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/14.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "exec",
+				Config: map[string]interface{}{
+					"command": "/bin/bash",
+					"args":    []interface{}{"-c", "for f in ${NOMAD_TASK_DIR}/*; do printf '%s\\t%{x}\\n' \"$f\"; done"},
+				},
+				Env: map[string]string{
+					"PS1":       "\\u@\\h:\\w\\$ ",
+					"SEPARATOR": "\t",
+					"BELL":      "\a",
+				},
+				Templates: []*api.Template{{
+					DestPath: ptrStr("local/upstreams.conf"),
+					EmbeddedTmpl: ptrStr(`{{ range service "db" }}
+server {{ .Address }}:{{ .Port }};	# ${NOMAD_ALLOC_ID}
+{{ end }}
+%{ if true }literal%{ endif }
+echo "C:\Program Files\"
+`),
+				}, {
+					DestPath:     ptrStr("secrets/env"),
+					EmbeddedTmpl: ptrStr("{{ with secret \"kv/data/db\" }}PASSWORD=\"{{ .Data.data.password }}\"{{ end }}\r\nDIR=${NOMAD_SECRETS_DIR}\r\n"),
+					Envvars:      ptrBool(true),
+				}},
+			}},
+		}},
+	})
+
+	compare(r, "fixtures/15.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		Type: ptrStr("batch"),
+		Update: &api.UpdateStrategy{
+			Stagger:          ptrDuration(30 * time.Second),
+			MaxParallel:      ptrInt(2),
+			HealthCheck:      ptrStr("checks"),
+			MinHealthyTime:   ptrDuration(10 * time.Second),
+			HealthyDeadline:  ptrDuration(5 * time.Minute),
+			ProgressDeadline: ptrDuration(10 * time.Minute),
+			Canary:           ptrInt(1),
+			AutoRevert:       ptrBool(true),
+			AutoPromote:      ptrBool(false),
+		},
+		Multiregion: &api.Multiregion{
+			Strategy: &api.MultiregionStrategy{
+				MaxParallel: ptrInt(1),
+				OnFailure:   ptrStr("fail_all"),
+			},
+			Regions: []*api.MultiregionRegion{
+				{Name: "west", Count: ptrInt(2), Datacenters: []string{"west-1"}, Meta: map[string]string{"my-key": "my-value-west"}},
+				{Name: "east", Count: ptrInt(1), Datacenters: []string{"east-1", "east-2"}},
+			},
+		},
+		Periodic: &api.PeriodicConfig{
+			Enabled:         ptrBool(true),
+			Spec:            ptrStr("*/15 * * * * *"),
+			ProhibitOverlap: ptrBool(true),
+			TimeZone:        ptrStr("America/New_York"),
+		},
+		ParameterizedJob: &api.ParameterizedJobConfig{
+			Payload:      "required",
+			MetaRequired: []string{"dispatcher_email"},
+			MetaOptional: []string{"pager_email"},
+		},
+		Reschedule: &api.ReschedulePolicy{
+			Attempts:      ptrInt(15),
+			Interval:      ptrDuration(time.Hour),
+			Delay:         ptrDuration(30 * time.Second),
+			DelayFunction: ptrStr("exponential"),
+			MaxDelay:      ptrDuration(120 * time.Minute),
+			Unlimited:     ptrBool(false),
+		},
+		Migrate: &api.MigrateStrategy{
+			MaxParallel:     ptrInt(1),
+			HealthCheck:     ptrStr("checks"),
+			MinHealthyTime:  ptrDuration(10 * time.Second),
+			HealthyDeadline: ptrDuration(5 * time.Minute),
+		},
+		ConsulToken: ptrStr("consul-token"),
+		VaultToken:  ptrStr("vault-token"),
+	})
+
+	compare(r, "fixtures/16.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Update: &api.UpdateStrategy{
+				MaxParallel: ptrInt(3),
+				Canary:      ptrInt(3),
+			},
+			Migrate: &api.MigrateStrategy{
+				MaxParallel: ptrInt(2),
+			},
+			ReschedulePolicy: &api.ReschedulePolicy{
+				Unlimited: ptrBool(true),
+			},
+			Consul:                    &api.Consul{Namespace: "infra"},
+			ShutdownDelay:             ptrDuration(5 * time.Second),
+			StopAfterClientDisconnect: ptrDuration(time.Minute),
+			Services: []*api.Service{{
+				Name:      "api",
+				PortLabel: "9001",
+				Connect: &api.ConsulConnect{
+					SidecarService: &api.ConsulSidecarService{
+						Tags: []string{"sidecar"},
+						Proxy: &api.ConsulProxy{
+							LocalServicePort: 9001,
+							ExposeConfig: &api.ConsulExposeConfig{
+								Path: []*api.ConsulExposePath{{
+									Path:          "/health",
+									Protocol:      "http",
+									LocalPathPort: 9001,
+									ListenerPort:  "expose",
+								}},
+							},
+							Upstreams: []*api.ConsulUpstream{{
+								DestinationName: "db",
+								LocalBindPort:   5432,
+								Datacenter:      "dc2",
+								MeshGateway:     &api.ConsulMeshGateway{Mode: "local"},
+							}},
+							Config: map[string]interface{}{"protocol": "http"},
+						},
+					},
+					SidecarTask: &api.SidecarTask{
+						Driver:      "docker",
+						Config:      map[string]interface{}{"image": "envoyproxy/envoy:v1.18.3"},
+						Resources:   &api.Resources{CPU: ptrInt(100), MemoryMB: ptrInt(64)},
+						KillTimeout: ptrDuration(10 * time.Second),
+						LogConfig:   &api.LogConfig{MaxFiles: ptrInt(2), MaxFileSizeMB: ptrInt(2)},
+					},
+				},
+			}},
+			Tasks: []*api.Task{{
+				Name:   "init",
+				Driver: "exec",
+				Lifecycle: &api.TaskLifecycle{
+					Hook:    "prestart",
+					Sidecar: false,
+				},
+			}, {
+				Name:   "server",
+				Driver: "exec",
+				User:   "nobody",
+				Leader: true,
+				LogConfig: &api.LogConfig{
+					MaxFiles:      ptrInt(10),
+					MaxFileSizeMB: ptrInt(15),
+				},
+				Vault: &api.Vault{
+					Policies:     []string{"nomad-cluster"},
+					Namespace:    ptrStr("ns"),
+					Env:          ptrBool(true),
+					ChangeMode:   ptrStr("signal"),
+					ChangeSignal: ptrStr("SIGHUP"),
+				},
+				CSIPluginConfig: &api.TaskCSIPluginConfig{
+					ID:       "csi-hostpath",
+					Type:     api.CSIPluginTypeMonolith,
+					MountDir: "/csi",
+				},
+				KillTimeout: ptrDuration(20 * time.Second),
+				KillSignal:  "SIGINT",
+				ScalingPolicies: []*api.ScalingPolicy{{
+					Type:    "vertical_cpu",
+					Enabled: ptrBool(true),
+					Policy:  map[string]interface{}{"cooldown": "5m"},
+				}},
+			}},
+		}},
+	})
+
+	// driver config, in the shape Nomad's API returns it
+	compare(r, "fixtures/17.hcl", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				Config: map[string]interface{}{
+					"image": "registry.example.com/nginx",
+					"ports": []interface{}{"http"},
+					"auth": []interface{}{map[string]interface{}{
+						"username": "deploy",
+						"password": "hunter2",
+					}},
+					"logging": []interface{}{map[string]interface{}{
+						"type": "syslog",
+						"config": []interface{}{map[string]interface{}{
+							"tag": "nginx",
+						}},
+					}},
+					"mount": []interface{}{
+						map[string]interface{}{
+							"type":   "bind",
+							"source": "local",
+							"target": "/etc/nginx",
+						},
+						map[string]interface{}{
+							"type":   "tmpfs",
+							"target": "/tmp",
+							"tmpfs_options": []interface{}{map[string]interface{}{
+								"size": float64(100000),
+							}},
+						},
+					},
+					"port_map": []interface{}{map[string]interface{}{
+						"http": float64(80),
+					}},
+				},
+			}, {
+				Name:   "app",
+				Driver: "podman",
+				Config: map[string]interface{}{
+					"image": "docker://redis",
+					"logging": []interface{}{map[string]interface{}{
+						"driver": "journald",
+						"options": []interface{}{map[string]interface{}{
+							"tag": "redis",
+						}},
+					}},
+				},
+			}, {
+				Name:   "nix",
+				Driver: "nix",
+				Config: map[string]interface{}{
+					"packages": []interface{}{"github:nixos/nixpkgs#bash"},
+					"command":  []interface{}{"bash", "-c", "sleep infinity"},
+				},
+			}},
+		}},
+	})
+}
+
+func TestJob2HclHeredoc(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name: "server",
+				Templates: []*api.Template{
+					{EmbeddedTmpl: ptrStr("a\n\n  b\nEOT\n")},
+					{EmbeddedTmpl: ptrStr("  indented\n  yaml\n")},
+					{EmbeddedTmpl: ptrStr("no final\nnewline")},
+				},
+			}},
+		}},
+	}
+
+	render := func(mode string) string {
+		f, _, err := any2hcl("job", job, Options{Heredoc: mode})
+		r.Nil(err)
+		r.Nil(Verify(job, f))
+		return string(f.Bytes())
+	}
+
+	r.Equal(`job "docs" {
+  group "example" {
+    task "server" {
+      template {
+        data = <<-EOF
+          a
+
+            b
+          EOT
+        EOF
+      }
+
+      template {
+        data = <<EOT
+  indented
+  yaml
+EOT
+      }
+
+      template {
+        data = "no final\nnewline"
+      }
+    }
+  }
+}
+`, render(HeredocIndent))
+
+	r.Equal(`job "docs" {
+  group "example" {
+    task "server" {
+      template {
+        data = <<EOF
+a
+
+  b
+EOT
+EOF
+      }
+
+      template {
+        data = <<EOT
+  indented
+  yaml
+EOT
+      }
+
+      template {
+        data = "no final\nnewline"
+      }
+    }
+  }
+}
+`, render(HeredocFlat))
+
+	r.Contains(render(HeredocNever), `data = "a\n\n  b\nEOT\n"`)
+
+	_, _, err := any2hcl("job", job, Options{Heredoc: "fancy"})
+	r.EqualError(err, `Unknown heredoc mode "fancy", use one of indent, flat or never`)
+}
+
+func TestJob2HclErrors(t *testing.T) {
+	r := require.New(t)
+
+	_, _, err := any2hcl("job", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name: "server",
+				Config: map[string]interface{}{
+					"image": "nginx",
+					"ports": []interface{}{"http", complex(1, 2)},
+				},
+			}, {
+				Name: "sidecar",
+				Config: map[string]interface{}{
+					"mounts": map[string]interface{}{"tmp": struct{}{}},
+				},
+			}},
+		}},
+	}, Options{})
+
+	r.IsType(ConversionErrors{}, err)
+	r.Len(err, 2)
+	r.Equal(strings.Join([]string{
+		`job["docs"].group["example"].task["server"].config.ports[1]: unsupported type complex128`,
+		`job["docs"].group["example"].task["sidecar"].config.mounts.tmp: unsupported type struct {}`,
+	}, "\n"), err.Error())
+}
+
+func compare(r *require.Assertions, fixturePath string, job *api.Job) {
+	b, err := ioutil.ReadFile(fixturePath)
+	r.Nil(err)
+	f, diags, err := Render(job, Options{})
+	r.Nil(err)
+	r.Empty(diags)
+	r.Equal(strings.TrimSpace(string(b)), strings.TrimSpace(string(f.Bytes())))
+
+	parsed := &api.Job{}
+	r.Nil(hcl2any(f.Bytes(), fixturePath, "job", parsed))
+	r.Equal(withVolumeNames(job), parsed)
+}
+
+// withVolumeNames fills in the names of volumes that don't have one with
+// their label, the way parsing the HCL does.
+func withVolumeNames(job *api.Job) *api.Job {
+	copied := *job
+	if job.TaskGroups == nil {
+		return &copied
+	}
+
+	copied.TaskGroups = make([]*api.TaskGroup, len(job.TaskGroups))
+	for i, group := range job.TaskGroups {
+		if group == nil || len(group.Volumes) == 0 {
+			copied.TaskGroups[i] = group
+			continue
+		}
+
+		g := *group
+		g.Volumes = map[string]*api.VolumeRequest{}
+		for label, volume := range group.Volumes {
+			if volume != nil && volume.Name == "" {
+				named := *volume
+				named.Name = label
+				volume = &named
+			}
+			g.Volumes[label] = volume
+		}
+		copied.TaskGroups[i] = &g
+	}
+
+	return &copied
+}
+
+func ptrStr(v string) *string {
+	return &v
+}
+
+func ptrInt(v int) *int {
+	return &v
+}
+
+func ptrInt8(v int8) *int8 {
+	return &v
+}
+
+func ptrInt64(v int64) *int64 {
+	return &v
+}
+
+func ptrUInt64(v uint64) *uint64 {
+	return &v
+}
+
+func ptrDuration(v time.Duration) *time.Duration {
+	return &v
+}
+
+func ptrBool(v bool) *bool {
+	return &v
+}
+func TestConverterWarnings(t *testing.T) {
+	r := require.New(t)
+
+	_, diags, err := any2hcl("job", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{
+				nil,
+				{Config: map[string]interface{}{"image": "nginx"}},
+			},
+			Volumes: map[string]*api.VolumeRequest{"certs": nil},
+		}},
+	}, Options{})
+	r.Nil(err)
+	r.Equal(diag.Diagnostics{
+		{Severity: diag.SeverityWarning, Source: "converter", Path: `job["docs"].group["example"].task[0]`, Message: "left out nil block"},
+		{Severity: diag.SeverityWarning, Source: "converter", Path: `job["docs"].group["example"].task[1]`, Message: "block has an empty name, Nomad will reject it"},
+		{Severity: diag.SeverityWarning, Source: "converter", Path: `job["docs"].group["example"].volume["certs"]`, Message: "left out nil block"},
+	}, diags)
+}
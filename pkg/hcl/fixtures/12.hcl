@@ -0,0 +1,45 @@
+job "docs" {
+  group "example" {
+    task "server" {
+      driver = "docker"
+
+      config {
+        cpu_hard_limit    = true
+        image             = "nginx"
+        memory_hard_limit = 512
+        ports             = ["http", 8080]
+      }
+
+      resources {
+        device "nvidia/gpu" {
+          count = 18446744073709551615
+        }
+      }
+    }
+
+    scaling {
+      min = 1
+      max = 10
+
+      policy {
+        cooldown            = "1m"
+        evaluation_interval = "30s"
+        target              = 0.5
+      }
+      enabled = true
+    }
+  }
+
+  spread {
+    attribute = "$${node.datacenter}"
+    weight    = 100
+
+    target "us-east1" {
+      percent = 60
+    }
+
+    target "us-west1" {
+      percent = 40
+    }
+  }
+}
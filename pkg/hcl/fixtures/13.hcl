@@ -0,0 +1,39 @@
+job "docs" {
+  group "example" {
+    volume "certs" {
+      type      = "host"
+      source    = "ca-certificates"
+      read_only = true
+    }
+
+    volume "data" {
+      type            = "csi"
+      source          = "mysql-data"
+      access_mode     = "single-node-writer"
+      attachment_mode = "file-system"
+
+      mount_options {
+        fs_type     = "ext4"
+        mount_flags = ["noatime"]
+      }
+      per_alloc = true
+    }
+
+    service {
+      name = "web"
+      port = "http"
+
+      check {
+        type     = "http"
+        path     = "/health"
+        interval = "10s"
+        timeout  = "2s"
+
+        header {
+          Authorization   = ["Basic ZWxhc3RpYzpjaGFuZ2VtZQ=="]
+          X-Forwarded-For = ["a", "b"]
+        }
+      }
+    }
+  }
+}
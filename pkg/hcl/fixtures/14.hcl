@@ -0,0 +1,35 @@
+job "docs" {
+  group "example" {
+    task "server" {
+      driver = "exec"
+
+      config {
+        args    = ["-c", "for f in $${NOMAD_TASK_DIR}/*; do printf '%s\\t%%{x}\\n' \"$f\"; done"]
+        command = "/bin/bash"
+      }
+
+      env {
+        BELL      = "\u0007"
+        PS1       = "\\u@\\h:\\w\\$ "
+        SEPARATOR = "\t"
+      }
+
+      template {
+        destination = "local/upstreams.conf"
+        data        = <<-EOT
+          {{ range service "db" }}
+          server {{ .Address }}:{{ .Port }};	# $${NOMAD_ALLOC_ID}
+          {{ end }}
+          %%{ if true }literal%%{ endif }
+          echo "C:\Program Files\"
+        EOT
+      }
+
+      template {
+        destination = "secrets/env"
+        data        = "{{ with secret \"kv/data/db\" }}PASSWORD=\"{{ .Data.data.password }}\"{{ end }}\r\nDIR=$${NOMAD_SECRETS_DIR}\r\n"
+        env         = true
+      }
+    }
+  }
+}
@@ -0,0 +1,67 @@
+job "docs" {
+  type = "batch"
+
+  update {
+    stagger           = "30s"
+    max_parallel      = 2
+    health_check      = "checks"
+    min_healthy_time  = "10s"
+    healthy_deadline  = "5m0s"
+    progress_deadline = "10m0s"
+    canary            = 1
+    auto_revert       = true
+    auto_promote      = false
+  }
+
+  multiregion {
+    strategy {
+      max_parallel = 1
+      on_failure   = "fail_all"
+    }
+
+    region "west" {
+      count       = 2
+      datacenters = ["west-1"]
+
+      meta {
+        my-key = "my-value-west"
+      }
+    }
+
+    region "east" {
+      count       = 1
+      datacenters = ["east-1", "east-2"]
+    }
+  }
+
+  periodic {
+    enabled          = true
+    cron             = "*/15 * * * * *"
+    prohibit_overlap = true
+    time_zone        = "America/New_York"
+  }
+
+  parameterized {
+    payload       = "required"
+    meta_required = ["dispatcher_email"]
+    meta_optional = ["pager_email"]
+  }
+
+  reschedule {
+    attempts       = 15
+    interval       = "1h0m0s"
+    delay          = "30s"
+    delay_function = "exponential"
+    max_delay      = "2h0m0s"
+    unlimited      = false
+  }
+
+  migrate {
+    max_parallel     = 1
+    health_check     = "checks"
+    min_healthy_time = "10s"
+    healthy_deadline = "5m0s"
+  }
+  consul_token = "consul-token"
+  vault_token  = "vault-token"
+}
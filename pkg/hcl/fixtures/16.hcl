@@ -0,0 +1,122 @@
+job "docs" {
+  group "example" {
+    task "init" {
+      driver = "exec"
+
+      lifecycle {
+        hook = "prestart"
+      }
+    }
+
+    task "server" {
+      driver       = "exec"
+      user         = "nobody"
+      kill_timeout = "20s"
+
+      logs {
+        max_files     = 10
+        max_file_size = 15
+      }
+
+      vault {
+        policies      = ["nomad-cluster"]
+        namespace     = "ns"
+        env           = true
+        change_mode   = "signal"
+        change_signal = "SIGHUP"
+      }
+
+      csi_plugin {
+        id        = "csi-hostpath"
+        type      = "monolith"
+        mount_dir = "/csi"
+      }
+      leader      = true
+      kill_signal = "SIGINT"
+
+      scaling {
+        policy {
+          cooldown = "5m"
+        }
+        enabled = true
+        type    = "vertical_cpu"
+      }
+    }
+
+    reschedule {
+      unlimited = true
+    }
+
+    update {
+      max_parallel = 3
+      canary       = 3
+    }
+
+    migrate {
+      max_parallel = 2
+    }
+
+    service {
+      name = "api"
+      port = "9001"
+
+      connect {
+        sidecar_service {
+          tags = ["sidecar"]
+
+          proxy {
+            local_service_port = 9001
+
+            expose {
+              path {
+                path            = "/health"
+                protocol        = "http"
+                local_path_port = 9001
+                listener_port   = "expose"
+              }
+            }
+
+            upstreams {
+              destination_name = "db"
+              local_bind_port  = 5432
+              datacenter       = "dc2"
+
+              mesh_gateway {
+                mode = "local"
+              }
+            }
+
+            config {
+              protocol = "http"
+            }
+          }
+        }
+
+        sidecar_task {
+          driver = "docker"
+
+          config {
+            image = "envoyproxy/envoy:v1.18.3"
+          }
+
+          resources {
+            cpu    = 100
+            memory = 64
+          }
+          kill_timeout = "10s"
+
+          logs {
+            max_files     = 2
+            max_file_size = 2
+          }
+        }
+      }
+    }
+    shutdown_delay               = "5s"
+    stop_after_client_disconnect = "1m0s"
+
+    consul {
+      namespace = "infra"
+    }
+  }
+}
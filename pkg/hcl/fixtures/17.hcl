@@ -0,0 +1,69 @@
+job "docs" {
+  group "example" {
+    task "server" {
+      driver = "docker"
+
+      config {
+        image = "registry.example.com/nginx"
+        ports = ["http"]
+
+        auth {
+          password = "hunter2"
+          username = "deploy"
+        }
+
+        logging {
+          type = "syslog"
+
+          config {
+            tag = "nginx"
+          }
+        }
+
+        mount {
+          source = "local"
+          target = "/etc/nginx"
+          type   = "bind"
+        }
+
+        mount {
+          target = "/tmp"
+          type   = "tmpfs"
+
+          tmpfs_options {
+            size = 100000
+          }
+        }
+
+        port_map {
+          http = 80
+        }
+      }
+    }
+
+    task "app" {
+      driver = "podman"
+
+      config {
+        image = "docker://redis"
+
+        logging {
+          driver = "journald"
+
+          options {
+            tag = "redis"
+          }
+        }
+      }
+    }
+
+    task "nix" {
+      driver = "nix"
+
+      config {
+        command  = ["bash", "-c", "sleep infinity"]
+        packages = ["github:nixos/nixpkgs#bash"]
+      }
+    }
+  }
+}
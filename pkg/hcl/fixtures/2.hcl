@@ -10,8 +10,8 @@ job "docs" {
         }
 
         headers {
-          User-Agent    = "nomad-[${NOMAD_JOB_ID}]-[${NOMAD_GROUP_NAME}]-[${NOMAD_TASK_NAME}]"
-          X-Nomad-Alloc = "${NOMAD_ALLOC_ID}"
+          User-Agent    = "nomad-[$${NOMAD_JOB_ID}]-[$${NOMAD_GROUP_NAME}]-[$${NOMAD_TASK_NAME}]"
+          X-Nomad-Alloc = "$${NOMAD_ALLOC_ID}"
         }
         destination = "local/some-directory"
       }
@@ -1,20 +1,20 @@
 job "docs" {
   affinity {
-    attribute = "${node.datacenter}"
+    attribute = "$${node.datacenter}"
     value     = "us-west1"
     weight    = 100
   }
 
   group "example" {
     affinity {
-      attribute = "${meta.rack}"
+      attribute = "$${meta.rack}"
       value     = "r1"
       weight    = 50
     }
 
     task "server" {
       affinity {
-        attribute = "${meta.my_custom_value}"
+        attribute = "$${meta.my_custom_value}"
         value     = "3"
         operator  = ">"
         weight    = 50
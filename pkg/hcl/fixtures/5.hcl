@@ -1,6 +1,6 @@
 job "docs" {
   constraint {
-    attribute = "${attr.kernel.name}"
+    attribute = "$${attr.kernel.name}"
     value     = "linux"
   }
 
@@ -12,7 +12,7 @@ job "docs" {
 
     task "server" {
       constraint {
-        attribute = "${meta.my_custom_value}"
+        attribute = "$${meta.my_custom_value}"
         value     = "3"
         operator  = ">"
       }
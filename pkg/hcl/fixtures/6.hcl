@@ -6,13 +6,13 @@ job "docs" {
           count = 2
 
           constraint {
-            attribute = "${device.attr.memory}"
+            attribute = "$${device.attr.memory}"
             value     = "2 GiB"
             operator  = ">="
           }
 
           affinity {
-            attribute = "${device.attr.memory}"
+            attribute = "$${device.attr.memory}"
             value     = "4 GiB"
             operator  = ">="
             weight    = 75
@@ -51,7 +51,7 @@ job "ingress-demo" {
 
       env {
         BIND = "0.0.0.0"
-        PORT = "${NOMAD_PORT_api}"
+        PORT = "$${NOMAD_PORT_api}"
       }
     }
 
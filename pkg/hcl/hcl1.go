@@ -0,0 +1,87 @@
+package hcl
+
+import (
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// setHcl1 writes a list, map or scalar in HCL1 syntax. hclwrite escapes
+// template sequences in nested strings, which HCL1 would keep as they are,
+// and HCL1 has no null.
+func (c *converter) setHcl1(body *hclwrite.Body, key string, value cty.Value) {
+	tokens, ok := c.hcl1Tokens(value)
+	if !ok {
+		c.warn("left out null, HCL1 has none", key)
+		return
+	}
+
+	body.SetAttributeRaw(key, tokens)
+}
+
+// hcl1Tokens returns the tokens of value, or false if it is or contains a
+// null.
+func (c *converter) hcl1Tokens(value cty.Value) (hclwrite.Tokens, bool) {
+	if value == cty.NilVal || value.IsNull() {
+		return nil, false
+	}
+
+	ty := value.Type()
+
+	switch {
+	case ty == cty.String:
+		return hclwrite.Tokens{
+			{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
+			{Type: hclsyntax.TokenStringLit, Bytes: []byte(escapeQuoted(value.AsString(), false))},
+			{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+		}, true
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		tokens := hclwrite.Tokens{{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")}}
+
+		for it := value.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+
+			elemTokens, ok := c.hcl1Tokens(elem)
+			if !ok {
+				return nil, false
+			}
+
+			if len(tokens) > 1 {
+				tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+			}
+			tokens = append(tokens, elemTokens...)
+		}
+
+		return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")}), true
+	case ty.IsMapType() || ty.IsObjectType():
+		tokens := hclwrite.Tokens{
+			{Type: hclsyntax.TokenOBrace, Bytes: []byte("{")},
+			{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		}
+
+		for it := value.ElementIterator(); it.Next(); {
+			k, elem := it.Element()
+
+			elemTokens, ok := c.hcl1Tokens(elem)
+			if !ok {
+				return nil, false
+			}
+
+			name := k.AsString()
+			if hclsyntax.ValidIdentifier(name) {
+				tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenIdent, Bytes: []byte(name)})
+			} else {
+				keyTokens, _ := c.hcl1Tokens(k)
+				tokens = append(tokens, keyTokens...)
+			}
+
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenEqual, Bytes: []byte("=")})
+			tokens = append(tokens, elemTokens...)
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		}
+
+		return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrace, Bytes: []byte("}")}), true
+	}
+
+	return hclwrite.TokensForValue(value), true
+}
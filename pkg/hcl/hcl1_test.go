@@ -0,0 +1,65 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob2Hcl1(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name:        ptrStr("docs"),
+		Datacenters: []string{"${node.datacenter}"},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				Config: map[string]interface{}{
+					"args":  []interface{}{"--port", "${NOMAD_PORT_http}"},
+					"image": "nginx",
+					"tty":   nil,
+				},
+				Templates: []*api.Template{{
+					EmbeddedTmpl: ptrStr("  port = ${NOMAD_PORT_http}\n  %{ not a directive }\n"),
+				}},
+			}},
+		}},
+	}
+
+	f, diags, err := Render(job, Options{Syntax: SyntaxHcl1})
+	r.Nil(err)
+	r.Len(diags, 1)
+	r.Equal(`warning: converter: job["docs"].group["example"].task["server"].config.tty: left out null, HCL1 has none`, diags[0].String())
+	r.Equal(`job "docs" {
+  datacenters = ["${node.datacenter}"]
+
+  group "example" {
+    task "server" {
+      driver = "docker"
+
+      config {
+        args  = ["--port", "${NOMAD_PORT_http}"]
+        image = "nginx"
+      }
+
+      template {
+        data = <<EOT
+  port = ${NOMAD_PORT_http}
+  %{ not a directive }
+EOT
+      }
+    }
+  }
+}`, strings.TrimSpace(string(f.Bytes())))
+
+	_, _, err = Render(job, Options{Syntax: SyntaxHcl1, ExtractVars: true})
+	r.EqualError(err, "Variables need hcl2, hcl1 has none")
+
+	_, _, err = Render(job, Options{Syntax: "hcl3"})
+	r.EqualError(err, `Unknown syntax "hcl3", use one of hcl2 or hcl1`)
+}
@@ -0,0 +1,243 @@
+package hcl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// variable is a value lifted out of the job into a `variable` block.
+type variable struct {
+	name  string
+	key   string
+	value cty.Value
+}
+
+// occurrence is an attribute value seen while looking for variables.
+type occurrence struct {
+	// attribute is the name of the first attribute with this value.
+	attribute string
+	value     cty.Value
+	count     int
+}
+
+// occurrences counts how often each attribute value appears in a job.
+type occurrences struct {
+	byKey map[string]*occurrence
+	order []string
+}
+
+func (o *occurrences) add(attribute string, value cty.Value) {
+	key, ok := variableKey(value)
+	if !ok {
+		return
+	}
+
+	if o.byKey == nil {
+		o.byKey = map[string]*occurrence{}
+	}
+
+	if found, ok := o.byKey[key]; ok {
+		found.count++
+		return
+	}
+
+	o.byKey[key] = &occurrence{attribute: attribute, value: value, count: 1}
+	o.order = append(o.order, key)
+}
+
+var (
+	// plainWord matches values like docker, bridge or http that are repeated
+	// because they're enumerations, not because they're deployment parameters.
+	plainWord = regexp.MustCompile(`^[a-z_\-]+$`)
+	// signalName matches signals like SIGINT, as in kill_signal.
+	signalName = regexp.MustCompile(`^SIG[A-Z0-9+\-]+$`)
+	// punctuation matches values without letters or digits, like the {{ of
+	// left_delimiter.
+	punctuation = regexp.MustCompile(`^[^A-Za-z0-9]+$`)
+)
+
+// isParameter is false for strings that repeat because they're tuning, like
+// durations, signals and template delimiters, rather than values that
+// change between deployments.
+func isParameter(value cty.Value) bool {
+	if value.Type() != cty.String {
+		return true
+	}
+
+	s := value.AsString()
+	if _, err := time.ParseDuration(s); err == nil {
+		return false
+	}
+
+	return !plainWord.MatchString(s) && !signalName.MatchString(s) && !punctuation.MatchString(s)
+}
+
+// variables picks the values that become variables: the configured ones,
+// and with ExtractVars every other parameter that appears more than once.
+func (o *occurrences) variables(opts Options) ([]*variable, diag.Diagnostics) {
+	vars := []*variable{}
+	warnings := diag.Diagnostics{}
+	taken := map[string]bool{}
+	lifted := map[string]bool{}
+
+	names := []string{}
+	for name := range opts.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := "string:" + opts.Variables[name]
+
+		found, ok := o.byKey[key]
+		if !ok {
+			warnings = append(warnings, &diag.Diagnostic{
+				Severity: diag.SeverityWarning,
+				Source:   "variables",
+				Message:  fmt.Sprintf("left out variable %s, no attribute is %q", name, opts.Variables[name]),
+			})
+			continue
+		}
+
+		if lifted[key] {
+			continue
+		}
+
+		vars = append(vars, &variable{name: name, key: key, value: found.value})
+		taken[name] = true
+		lifted[key] = true
+	}
+
+	if opts.ExtractVars {
+		for _, key := range o.order {
+			found := o.byKey[key]
+			if found.count < 2 || lifted[key] {
+				continue
+			}
+
+			if !isParameter(found.value) {
+				continue
+			}
+
+			name := variableName(found.attribute)
+			for i := 2; taken[name]; i++ {
+				name = fmt.Sprintf("%s_%d", variableName(found.attribute), i)
+			}
+
+			vars = append(vars, &variable{name: name, key: key, value: found.value})
+			taken[name] = true
+			lifted[key] = true
+		}
+	}
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].name < vars[j].name })
+
+	return vars, warnings
+}
+
+// variableKey identifies the values that can become variables: single-line
+// strings and lists of strings.
+func variableKey(value cty.Value) (string, bool) {
+	if value == cty.NilVal || value.IsNull() || !value.IsWhollyKnown() {
+		return "", false
+	}
+
+	switch {
+	case value.Type() == cty.String:
+		s := value.AsString()
+		if s == "" || strings.Contains(s, "\n") {
+			return "", false
+		}
+		return "string:" + s, true
+	case value.Type().IsListType() && value.Type().ElementType() == cty.String && value.LengthInt() > 0:
+		elems := []string{}
+		for it := value.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			elems = append(elems, strconv.Quote(elem.AsString()))
+		}
+		return "list:" + strings.Join(elems, ","), true
+	}
+
+	return "", false
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// variableName turns an attribute name, or a meta or env key, into a valid
+// variable name.
+func variableName(attribute string) string {
+	name := nonIdentifier.ReplaceAllString(attribute, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "var_" + name
+	}
+
+	return name
+}
+
+// writeVariables writes a `variable` block with the type and default of
+// each variable.
+func (c *converter) writeVariables(body *hclwrite.Body, vars []*variable) {
+	for i, v := range vars {
+		if i > 0 {
+			body.AppendNewline()
+		}
+
+		block := body.AppendNewBlock("variable", []string{v.name}).Body()
+
+		if v.value.Type() == cty.String {
+			block.SetAttributeRaw("type", hclwrite.Tokens{
+				{Type: hclsyntax.TokenIdent, Bytes: []byte("string")},
+			})
+		} else {
+			block.SetAttributeRaw("type", hclwrite.Tokens{
+				{Type: hclsyntax.TokenIdent, Bytes: []byte("list")},
+				{Type: hclsyntax.TokenOParen, Bytes: []byte("(")},
+				{Type: hclsyntax.TokenIdent, Bytes: []byte("string")},
+				{Type: hclsyntax.TokenCParen, Bytes: []byte(")")},
+			})
+		}
+
+		c.depth++
+		c.setCty(block, "default", v.value)
+		c.depth--
+	}
+}
+
+// setVariable writes a reference to the variable for value instead of the
+// value itself, if there is one.
+func (c *converter) setVariable(body *hclwrite.Body, key string, value cty.Value) bool {
+	if c.occurrences != nil {
+		c.occurrences.add(key, value)
+	}
+
+	if len(c.variables) == 0 {
+		return false
+	}
+
+	valueKey, ok := variableKey(value)
+	if !ok {
+		return false
+	}
+
+	name, ok := c.variables[valueKey]
+	if !ok {
+		return false
+	}
+
+	body.SetAttributeTraversal(key, hcl.Traversal{
+		hcl.TraverseRoot{Name: "var"},
+		hcl.TraverseAttr{Name: name},
+	})
+
+	return true
+}
@@ -0,0 +1,208 @@
+package hcl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractVars(t *testing.T) {
+	r := require.New(t)
+
+	task := func(name string) *api.Task {
+		return &api.Task{
+			Name:   name,
+			Driver: "docker",
+			Config: map[string]interface{}{
+				"image": "registry.example.com/app:1.2.3",
+			},
+			Env: map[string]string{"RELEASE": "1.2.3"},
+		}
+	}
+
+	job := &api.Job{
+		Name:        ptrStr("app"),
+		Datacenters: []string{"eu-central-1", "us-east-2"},
+		TaskGroups: []*api.TaskGroup{{
+			Name:  ptrStr("api"),
+			Tasks: []*api.Task{task("server"), task("worker")},
+		}},
+	}
+
+	f, diags, err := Render(job, Options{
+		ExtractVars: true,
+		Variables:   map[string]string{"release": "1.2.3", "missing": "nowhere"},
+	})
+	r.Nil(err)
+	r.Len(diags, 1)
+	r.Equal("warning: variables: left out variable missing, no attribute is \"nowhere\"", diags[0].String())
+	r.Equal(`variable "image" {
+  type    = string
+  default = "registry.example.com/app:1.2.3"
+}
+
+variable "release" {
+  type    = string
+  default = "1.2.3"
+}
+
+job "app" {
+  datacenters = ["eu-central-1", "us-east-2"]
+
+  group "api" {
+    task "server" {
+      driver = "docker"
+
+      config {
+        image = var.image
+      }
+
+      env {
+        RELEASE = var.release
+      }
+    }
+
+    task "worker" {
+      driver = "docker"
+
+      config {
+        image = var.image
+      }
+
+      env {
+        RELEASE = var.release
+      }
+    }
+  }
+}`, strings.TrimSpace(string(f.Bytes())))
+
+	parsed, err := Parse(f.Bytes(), "app.hcl")
+	r.Nil(err)
+	r.Equal(job, parsed)
+	r.Nil(Verify(job, f))
+}
+
+func TestExtractVarsNames(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name:        ptrStr("app"),
+		Datacenters: []string{"eu-central-1"},
+		Meta: map[string]string{
+			"git-sha": "0123abc",
+			"commit":  "0123abc",
+			"driver":  "docker",
+		},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("api"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "docker",
+				Meta:   map[string]string{"git-sha": "0123abc"},
+			}},
+		}},
+	}
+
+	f, diags, err := Render(job, Options{ExtractVars: true})
+	r.Nil(err)
+	r.Empty(diags)
+
+	// the first attribute with the value names it, plain words stay inline
+	src := string(f.Bytes())
+	r.Contains(src, `variable "git_sha" {`)
+	r.Contains(src, `commit  = var.git_sha`)
+	r.Contains(src, `driver = "docker"`)
+	r.NotContains(src, `variable "datacenters"`)
+
+	parsed, err := Parse(f.Bytes(), "app.hcl")
+	r.Nil(err)
+	r.Equal(job, parsed)
+}
+
+func TestExtractVarsParameters(t *testing.T) {
+	r := require.New(t)
+
+	task := func(name string) *api.Task {
+		return &api.Task{
+			Name:       name,
+			Driver:     "exec",
+			KillSignal: "SIGINT",
+			Config:     map[string]interface{}{"flake": "github:input-output-hk/app#server"},
+			Templates: []*api.Template{{
+				DestPath:     ptrStr("local/" + name),
+				EmbeddedTmpl: ptrStr(name + " = {{ env \"NOMAD_PORT_http\" }}"),
+				LeftDelim:    ptrStr("{{"),
+				RightDelim:   ptrStr("}}"),
+				Splay:        ptrDuration(0),
+			}},
+		}
+	}
+
+	restart := func() *api.RestartPolicy {
+		return &api.RestartPolicy{
+			Attempts: ptrInt(3),
+			Delay:    ptrDuration(30 * time.Second),
+			Interval: ptrDuration(0),
+			Mode:     ptrStr("delay"),
+		}
+	}
+
+	job := &api.Job{
+		Name:        ptrStr("app"),
+		Datacenters: []string{"eu-central-1"},
+		TaskGroups: []*api.TaskGroup{
+			{Name: ptrStr("api"), RestartPolicy: restart(), Tasks: []*api.Task{task("server")}},
+			{Name: ptrStr("worker"), RestartPolicy: restart(), Tasks: []*api.Task{task("worker")}},
+		},
+	}
+
+	f, diags, err := Render(job, Options{ExtractVars: true})
+	r.Nil(err)
+	r.Empty(diags)
+
+	// only the flake is a parameter, the rest just happens to be the same
+	src := string(f.Bytes())
+	r.Equal(1, strings.Count(src, "variable \""))
+	r.Contains(src, `variable "flake" {`)
+	r.Contains(src, `delay    = "30s"`)
+	r.Contains(src, `interval = "0s"`)
+	r.Contains(src, `kill_signal = "SIGINT"`)
+	r.Contains(src, `left_delimiter  = "{{"`)
+
+	parsed, err := Parse(f.Bytes(), "app.hcl")
+	r.Nil(err)
+	r.Equal(job, parsed)
+}
+
+func TestParseVariables(t *testing.T) {
+	r := require.New(t)
+
+	job, err := Parse([]byte(`
+variable "datacenters" {
+  type    = list(string)
+  default = ["dc1", "dc2"]
+}
+
+job "app" {
+  datacenters = var.datacenters
+  meta = {
+    port = "${NOMAD_PORT_http}"
+  }
+}
+`), "app.hcl")
+	r.Nil(err)
+	r.Equal([]string{"dc1", "dc2"}, job.Datacenters)
+	r.Equal("${NOMAD_PORT_http}", job.Meta["port"])
+
+	_, err = Parse([]byte(`
+variable "datacenters" {}
+
+job "app" {
+  datacenters = var.datacenters
+}
+`), "app.hcl")
+	r.EqualError(err, `app.hcl:2,1-9: Missing variable default; The variable "datacenters" has no default.`)
+}
@@ -0,0 +1,275 @@
+package hcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/pkg/errors"
+)
+
+// VerifyError lists every field path where the generated HCL doesn't decode
+// back into the job it was generated from.
+type VerifyError struct {
+	Diffs []string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("HCL is not equivalent to the input job:\n  %s", strings.Join(e.Diffs, "\n  "))
+}
+
+// Verify parses file back into an api.Job the way Parse does and compares
+// it with job.
+func Verify(job *api.Job, file *hclwrite.File) error {
+	parsed := &api.Job{}
+	if err := hcl2any(file.Bytes(), "<generated>", "job", parsed); err != nil {
+		return errors.WithMessage(err, "Trying to parse generated HCL")
+	}
+
+	diffs := diffHcl("", reflect.ValueOf(job), reflect.ValueOf(parsed))
+	if len(diffs) > 0 {
+		return &VerifyError{Diffs: diffs}
+	}
+
+	return nil
+}
+
+// diffHcl compares the fields of a and b that have an `hcl` tag. Nil and
+// empty slices or maps are considered equal, since HCL can't tell them apart.
+func diffHcl(path string, a, b reflect.Value) []string {
+	if a.Kind() == reflect.Ptr || a.Kind() == reflect.Interface {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				return []string{fmt.Sprintf("%s: %s became %s", pathOrRoot(path), formatDiffValue(a), formatDiffValue(b))}
+			}
+			return nil
+		}
+
+		a, b = a.Elem(), b.Elem()
+	}
+
+	if a.Kind() != b.Kind() {
+		if isNumberKind(a.Kind()) && isNumberKind(b.Kind()) && numberOf(a) == numberOf(b) {
+			return nil
+		}
+
+		// config objects that are written as a block, like docker's logging,
+		// come back as a list with one object, just like Nomad decodes them.
+		if a.Kind() == reflect.Map && b.Kind() == reflect.Slice && b.Len() == 1 {
+			elem := b.Index(0)
+			if elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			return diffHcl(path, a, elem)
+		}
+
+		return []string{fmt.Sprintf("%s: %s became %s", pathOrRoot(path), formatDiffValue(a), formatDiffValue(b))}
+	}
+
+	diffs := []string{}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		objType := a.Type()
+
+		for i := 0; i < objType.NumField(); i++ {
+			tag := objType.Field(i).Tag.Get("hcl")
+			if tag == "" {
+				continue
+			}
+
+			if name, _, _, _, _ := parseHclTag(tag); name == "-" {
+				continue
+			}
+
+			fieldPath := objType.Field(i).Name
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+
+			diffs = append(diffs, diffHcl(fieldPath, a.Field(i), b.Field(i))...)
+		}
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			diffs = append(diffs, fmt.Sprintf("%s: %d elements became %d", pathOrRoot(path), a.Len(), b.Len()))
+		}
+
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			diffs = append(diffs, diffHcl(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i))...)
+		}
+	case reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, k := range a.MapKeys() {
+			keys[k.String()] = k
+		}
+		for _, k := range b.MapKeys() {
+			keys[k.String()] = k
+		}
+
+		sorted := []string{}
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		for _, k := range sorted {
+			entryPath := fmt.Sprintf("%s[%q]", path, k)
+			av, bv := a.MapIndex(keys[k]), b.MapIndex(keys[k])
+
+			switch {
+			case !bv.IsValid():
+				diffs = append(diffs, fmt.Sprintf("%s: missing in HCL", entryPath))
+			case !av.IsValid():
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected in HCL", entryPath))
+			default:
+				diffs = append(diffs, diffHcl(entryPath, av, bv)...)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			diffs = append(diffs, fmt.Sprintf("%s: %s became %s", pathOrRoot(path), formatDiffValue(a), formatDiffValue(b)))
+		}
+	}
+
+	return diffs
+}
+
+func numberOf(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+func formatDiffValue(v reflect.Value) string {
+	switch {
+	case !v.IsValid():
+		return "nil"
+	case (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil():
+		return "nil"
+	case v.Kind() == reflect.Ptr:
+		return formatDiffValue(v.Elem())
+	case v.Type() == durationType:
+		return fmt.Sprintf("%v", v.Interface())
+	case v.Kind() == reflect.Struct:
+		return v.Type().String()
+	default:
+		return fmt.Sprintf("%#v", v.Interface())
+	}
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "Job"
+	}
+
+	return path
+}
+
+// Coverage warns about every non-zero field of job that doesn't make it
+// into the HCL, either because it has no `hcl` tag or because the conversion
+// lost it.
+func Coverage(job *api.Job, file *hclwrite.File) (diag.Diagnostics, error) {
+	parsed := &api.Job{}
+	if err := hcl2any(file.Bytes(), "<generated>", "job", parsed); err != nil {
+		return nil, errors.WithMessage(err, "Trying to parse generated HCL")
+	}
+
+	return coverage("", reflect.ValueOf(job), reflect.ValueOf(parsed)), nil
+}
+
+func coverageMissing(path, message string) *diag.Diagnostic {
+	return &diag.Diagnostic{Severity: diag.SeverityWarning, Source: "coverage", Path: path, Message: message}
+}
+
+func coverage(path string, in, out reflect.Value) diag.Diagnostics {
+	if !in.IsValid() || in.IsZero() {
+		return nil
+	}
+
+	if in.Kind() == reflect.Ptr || in.Kind() == reflect.Interface {
+		if !out.IsValid() || out.IsNil() {
+			return diag.Diagnostics{coverageMissing(pathOrRoot(path), "lost in conversion")}
+		}
+
+		in, out = in.Elem(), out.Elem()
+	}
+
+	if in.Kind() != out.Kind() {
+		if len(diffHcl(path, in, out)) > 0 {
+			return diag.Diagnostics{coverageMissing(pathOrRoot(path), "lost in conversion")}
+		}
+		return nil
+	}
+
+	missing := diag.Diagnostics{}
+
+	switch in.Kind() {
+	case reflect.Struct:
+		objType := in.Type()
+
+		for i := 0; i < objType.NumField(); i++ {
+			field := objType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+
+			tag := field.Tag.Get("hcl")
+			if tag == "" || tag == "-" {
+				if !in.Field(i).IsZero() {
+					missing = append(missing, coverageMissing(fieldPath, "no hcl tag"))
+				}
+				continue
+			}
+
+			missing = append(missing, coverage(fieldPath, in.Field(i), out.Field(i))...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < in.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= out.Len() {
+				missing = append(missing, coverageMissing(elemPath, "lost in conversion"))
+				continue
+			}
+
+			missing = append(missing, coverage(elemPath, in.Index(i), out.Index(i))...)
+		}
+	case reflect.Map:
+		keys := []string{}
+		for _, k := range in.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			entryPath := fmt.Sprintf("%s[%q]", path, k)
+			key := reflect.ValueOf(k).Convert(in.Type().Key())
+
+			if !out.MapIndex(key).IsValid() {
+				missing = append(missing, coverageMissing(entryPath, "lost in conversion"))
+				continue
+			}
+
+			missing = append(missing, coverage(entryPath, in.MapIndex(key), out.MapIndex(key))...)
+		}
+	default:
+		if len(diffHcl(path, in, out)) > 0 {
+			missing = append(missing, coverageMissing(pathOrRoot(path), "lost in conversion"))
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,119 @@
+package hcl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyHcl(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Config: map[string]interface{}{"image": "nginx", "args": []interface{}{"-v"}},
+				Templates: []*api.Template{{
+					EmbeddedTmpl: ptrStr("{{ key \"foo\" }}"),
+				}},
+			}},
+		}},
+	}
+
+	f, _, err := any2hcl("job", job, Options{})
+	r.Nil(err)
+	r.Nil(Verify(job, f))
+}
+
+func TestDiffHcl(t *testing.T) {
+	r := require.New(t)
+
+	a := &api.Job{
+		Name: ptrStr("docs"),
+		Meta: map[string]string{"a": "1", "b": "2"},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{
+				{Name: "one"},
+				{
+					Name:        "two",
+					KillTimeout: ptrDuration(5 * time.Second),
+					Config:      map[string]interface{}{"port": 8080},
+					Templates: []*api.Template{{
+						EmbeddedTmpl: ptrStr("hello"),
+					}},
+				},
+			},
+		}},
+		// not part of the HCL, so never reported
+		Status: ptrStr("running"),
+	}
+
+	b := &api.Job{
+		Name: ptrStr("docs"),
+		Meta: map[string]string{"a": "1", "c": "3"},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{
+				{Name: "one"},
+				{
+					Name:   "two",
+					Config: map[string]interface{}{"port": float64(8080)},
+					Templates: []*api.Template{{
+						EmbeddedTmpl: ptrStr("hello\n"),
+					}},
+				},
+			},
+		}},
+	}
+
+	r.Equal([]string{
+		`TaskGroups[0].Tasks[1].KillTimeout: 5s became nil`,
+		`TaskGroups[0].Tasks[1].Templates[0].EmbeddedTmpl: "hello" became "hello\n"`,
+		`Meta["b"]: missing in HCL`,
+		`Meta["c"]: unexpected in HCL`,
+	}, diffHcl("", reflect.ValueOf(a), reflect.ValueOf(b)))
+}
+
+func TestCoverageHcl(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Config: map[string]interface{}{"image": "nginx", "port": 8080},
+			}},
+		}},
+		Status:      ptrStr("running"),
+		SubmitTime:  ptrInt64(1),
+		Datacenters: []string{"dc1"},
+	}
+
+	f, _, err := any2hcl("job", job, Options{})
+	r.Nil(err)
+
+	missing, err := Coverage(job, f)
+	r.Nil(err)
+	r.Equal("warning: coverage: Status: no hcl tag\n"+
+		"warning: coverage: SubmitTime: no hcl tag", missing.Error())
+
+	f.Body().Blocks()[0].Body().RemoveAttribute("datacenters")
+
+	missing, err = Coverage(job, f)
+	r.Nil(err)
+	r.Equal(diag.Diagnostics{
+		{Severity: diag.SeverityWarning, Source: "coverage", Path: "Datacenters[0]", Message: "lost in conversion"},
+		{Severity: diag.SeverityWarning, Source: "coverage", Path: "Status", Message: "no hcl tag"},
+		{Severity: diag.SeverityWarning, Source: "coverage", Path: "SubmitTime", Message: "no hcl tag"},
+	}, missing)
+}
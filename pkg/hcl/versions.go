@@ -0,0 +1,204 @@
+package hcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// nomadVersion is a Nomad release, like 1.1.4.
+type nomadVersion [3]int
+
+// parseNomadVersion reads versions like 1.1.4, v1.0.0 or 1.2.0-beta1+ent.
+// Prereleases count as the release they lead up to.
+func parseNomadVersion(s string) (nomadVersion, error) {
+	v := nomadVersion{}
+
+	trimmed := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(trimmed, "-+"); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+
+	parts := strings.Split(trimmed, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("Invalid Nomad version %q, use one like 1.1.4", s)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("Invalid Nomad version %q, use one like 1.1.4", s)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+func (v nomadVersion) less(other nomadVersion) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+
+	return false
+}
+
+func (v nomadVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// fieldVersion says how to write a field of the pinned api for Nomad
+// versions that don't know it.
+type fieldVersion struct {
+	// Type is the struct with the field, Name its hcl name.
+	Type reflect.Type
+	Name string
+	// Since is the first version that knows the field, zero for all of
+	// them.
+	Since nomadVersion
+	// Warn keeps the field and warns with this message instead of leaving
+	// it out.
+	Warn string
+}
+
+// fieldVersions lists the fields that not every Nomad version accepts.
+// Fields missing from it are written for every version.
+var fieldVersions = []*fieldVersion{
+	{Type: reflect.TypeOf(api.Job{}), Name: "multiregion", Since: nomadVersion{0, 12, 0}},
+	{Type: reflect.TypeOf(api.Job{}), Name: "multiregion", Warn: "only Nomad Enterprise runs multiregion jobs"},
+	{Type: reflect.TypeOf(api.TaskGroup{}), Name: "stop_after_client_disconnect", Since: nomadVersion{0, 11, 2}},
+	{Type: reflect.TypeOf(api.TaskGroup{}), Name: "consul", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.Task{}), Name: "lifecycle", Since: nomadVersion{0, 11, 0}},
+	{Type: reflect.TypeOf(api.Resources{}), Name: "cores", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.Resources{}), Name: "memory_max", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.Port{}), Name: "host_network", Since: nomadVersion{0, 12, 0}},
+	{Type: reflect.TypeOf(api.VolumeRequest{}), Name: "per_alloc", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.VolumeRequest{}), Name: "access_mode", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.VolumeRequest{}), Name: "attachment_mode", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.Service{}), Name: "on_update", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.ServiceCheck{}), Name: "on_update", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.ConsulUpstream{}), Name: "local_bind_address", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.ConsulUpstream{}), Name: "mesh_gateway", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.ConsulGateway{}), Name: "terminating", Since: nomadVersion{1, 0, 0}},
+	{Type: reflect.TypeOf(api.ConsulGateway{}), Name: "mesh", Since: nomadVersion{1, 1, 0}},
+	{Type: reflect.TypeOf(api.ConsulGatewayProxy{}), Name: "envoy_gateway_bind_tagged_addresses", Since: nomadVersion{0, 12, 4}},
+	{Type: reflect.TypeOf(api.ConsulGatewayProxy{}), Name: "envoy_gateway_bind_addresses", Since: nomadVersion{0, 12, 4}},
+	{Type: reflect.TypeOf(api.ConsulGatewayProxy{}), Name: "envoy_gateway_no_default_bind", Since: nomadVersion{0, 12, 4}},
+}
+
+// versionedField is false if field has to be left out for the target
+// version.
+func (c *converter) versionedField(objType reflect.Type, field *structField) bool {
+	if c.target == nil || !isSetField(field) {
+		return true
+	}
+
+	for _, fv := range fieldVersions {
+		if fv.Type != objType || fv.Name != field.Name {
+			continue
+		}
+
+		if fv.Since != (nomadVersion{}) && !c.target.less(fv.Since) {
+			continue
+		}
+
+		switch {
+		case fv.Warn != "":
+			c.warn(fv.Warn, field.Name)
+		default:
+			c.warn(fmt.Sprintf("left out, Nomad %s doesn't know it before %s", c.target, fv.Since), field.Name)
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterVersion returns a copy of job without the fields that Render leaves
+// out for the given Nomad version, which is what the rendered HCL decodes
+// back to. It returns job itself if version is empty.
+func FilterVersion(job *api.Job, version string) (*api.Job, error) {
+	if version == "" {
+		return job, nil
+	}
+
+	target, err := parseNomadVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := &api.Job{}
+	if err := json.Unmarshal(src, filtered); err != nil {
+		return nil, err
+	}
+
+	filterVersion(reflect.ValueOf(filtered), target)
+
+	return filtered, nil
+}
+
+func filterVersion(v reflect.Value, target nomadVersion) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			filterVersion(v.Elem(), target)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			filterVersion(v.Index(i), target)
+		}
+	case reflect.Map:
+		// the values are pointers, like the volumes of a group, so what they
+		// point to can be changed in place
+		for _, k := range v.MapKeys() {
+			filterVersion(v.MapIndex(k), target)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _, _, _ := parseHclTag(v.Type().Field(i).Tag.Get("hcl"))
+			if name != "" && v.Field(i).CanSet() && isLeftOut(v.Type(), name, target) {
+				v.Field(i).Set(reflect.Zero(v.Field(i).Type()))
+				continue
+			}
+
+			filterVersion(v.Field(i), target)
+		}
+	}
+}
+
+// isLeftOut is true if the field is left out for the target version, rather
+// than kept with a warning.
+func isLeftOut(objType reflect.Type, name string, target nomadVersion) bool {
+	for _, fv := range fieldVersions {
+		if fv.Type == objType && fv.Name == name && fv.Since != (nomadVersion{}) && target.less(fv.Since) && fv.Warn == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSetField is true if field would end up in the HCL at all.
+func isSetField(field *structField) bool {
+	v := reflect.ValueOf(field.Value)
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Slice, reflect.Map:
+		return v.Len() > 0
+	}
+
+	return !v.IsZero()
+}
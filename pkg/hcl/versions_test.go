@@ -0,0 +1,198 @@
+package hcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNomadVersion(t *testing.T) {
+	r := require.New(t)
+
+	for input, expected := range map[string]nomadVersion{
+		"1.1.4":            {1, 1, 4},
+		"v1.0.0":           {1, 0, 0},
+		"1.2.0-beta1+ent":  {1, 2, 0},
+		"0.12":             {0, 12, 0},
+		" 1.1.4\n":         {1, 1, 4},
+		"1.10.0-dev+dirty": {1, 10, 0},
+	} {
+		v, err := parseNomadVersion(input)
+		r.Nil(err, input)
+		r.Equal(expected, v, input)
+	}
+
+	_, err := parseNomadVersion("one.two")
+	r.EqualError(err, `Invalid Nomad version "one.two", use one like 1.1.4`)
+
+	r.True(nomadVersion{0, 12, 4}.less(nomadVersion{1, 0, 0}))
+	r.False(nomadVersion{1, 1, 0}.less(nomadVersion{1, 1, 0}))
+}
+
+func TestNomadVersionFields(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:   "server",
+				Driver: "exec",
+				Resources: &api.Resources{
+					CPU:         ptrInt(100),
+					MemoryMB:    ptrInt(256),
+					MemoryMaxMB: ptrInt(512),
+				},
+			}},
+		}},
+	}
+
+	render := func(version string) (string, []string) {
+		f, diags, err := Render(job, Options{NomadVersion: version})
+		r.Nil(err)
+
+		msgs := []string{}
+		for _, d := range diags {
+			msgs = append(msgs, d.String())
+		}
+
+		return strings.TrimSpace(string(f.Bytes())), msgs
+	}
+
+	src, diags := render("1.1.4")
+	r.Contains(src, "memory_max = 512")
+	r.Empty(diags)
+
+	src, diags = render("1.0.4")
+	r.NotContains(src, "memory_max")
+	r.Contains(src, "memory = 256")
+	r.Equal([]string{
+		`warning: converter: job["docs"].group["example"].task["server"].resources.memory_max: left out, Nomad 1.0.4 doesn't know it before 1.1.0`,
+	}, diags)
+
+	// without a version every field of the pinned api is written
+	src, diags = render("")
+	r.Contains(src, "memory_max = 512")
+	r.Empty(diags)
+
+	_, _, err := Render(job, Options{NomadVersion: "latest"})
+	r.EqualError(err, `Invalid Nomad version "latest", use one like 1.1.4`)
+}
+
+func TestNomadVersionWarn(t *testing.T) {
+	r := require.New(t)
+
+	defer func(saved []*fieldVersion) { fieldVersions = saved }(fieldVersions)
+	fieldVersions = []*fieldVersion{
+		{Type: reflect.TypeOf(api.Resources{}), Name: "cpu", Warn: "cpu is only a hint"},
+	}
+
+	f, diags, err := Render(&api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:      "server",
+				Driver:    "exec",
+				Resources: &api.Resources{CPU: ptrInt(100)},
+			}},
+		}},
+	}, Options{NomadVersion: "1.0.0"})
+	r.Nil(err)
+	r.Contains(string(f.Bytes()), "cpu = 100")
+	r.Len(diags, 1)
+	r.Equal(`warning: converter: job["docs"].group["example"].task["server"].resources.cpu: cpu is only a hint`, diags[0].String())
+}
+
+func TestFilterVersion(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{{
+				Name:      "server",
+				Driver:    "exec",
+				Resources: &api.Resources{MemoryMB: ptrInt(256), MemoryMaxMB: ptrInt(512)},
+			}},
+		}},
+	}
+
+	filtered, err := FilterVersion(job, "1.0.4")
+	r.Nil(err)
+	r.Nil(filtered.TaskGroups[0].Tasks[0].Resources.MemoryMaxMB)
+	r.Equal(256, *filtered.TaskGroups[0].Tasks[0].Resources.MemoryMB)
+	r.Equal(512, *job.TaskGroups[0].Tasks[0].Resources.MemoryMaxMB, "job itself is left alone")
+
+	f, _, err := Render(job, Options{NomadVersion: "1.0.4"})
+	r.Nil(err)
+	r.Nil(Verify(filtered, f))
+
+	filtered, err = FilterVersion(job, "1.1.0")
+	r.Nil(err)
+	r.Equal(512, *filtered.TaskGroups[0].Tasks[0].Resources.MemoryMaxMB)
+
+	filtered, err = FilterVersion(job, "")
+	r.Nil(err)
+	r.True(filtered == job)
+}
+
+func TestFilterVersionVolumes(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Volumes: map[string]*api.VolumeRequest{
+				"data": {
+					Name:       "data",
+					Type:       "csi",
+					Source:     "mysql-data",
+					PerAlloc:   true,
+					AccessMode: "single-node-writer",
+				},
+			},
+			Tasks: []*api.Task{{Name: "server", Driver: "exec"}},
+		}},
+	}
+
+	f, diags, err := Render(job, Options{NomadVersion: "1.0.0"})
+	r.Nil(err)
+	r.Len(diags, 2)
+	r.NotContains(string(f.Bytes()), "per_alloc")
+	r.NotContains(string(f.Bytes()), "access_mode")
+
+	filtered, err := FilterVersion(job, "1.0.0")
+	r.Nil(err)
+	volume := filtered.TaskGroups[0].Volumes["data"]
+	r.False(volume.PerAlloc)
+	r.Empty(volume.AccessMode)
+	r.Equal("mysql-data", volume.Source)
+	r.True(job.TaskGroups[0].Volumes["data"].PerAlloc, "job itself is left alone")
+
+	r.Nil(Verify(filtered, f))
+	missing, err := Coverage(filtered, f)
+	r.Nil(err)
+	r.Empty(missing)
+}
+
+// TestFieldVersions checks that the table only names fields that exist.
+func TestFieldVersions(t *testing.T) {
+	r := require.New(t)
+
+	for _, fv := range fieldVersions {
+		names := map[string]bool{}
+		for i := 0; i < fv.Type.NumField(); i++ {
+			name, _, _, _, _ := parseHclTag(fv.Type.Field(i).Tag.Get("hcl"))
+			names[name] = true
+		}
+
+		r.True(names[fv.Name], "%s has no field %s", fv.Type, fv.Name)
+	}
+}
@@ -0,0 +1,155 @@
+// Package lint finds mistakes in Nomad jobs that schemas don't catch, like
+// a service on a port label the group doesn't define.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+// IgnoreMeta is the meta key that suppresses rules for a job, group or task
+// and everything in it, like
+//
+//	meta {
+//	  iogo_lint_ignore = "task-resources,template-destination"
+//	}
+const IgnoreMeta = "iogo_lint_ignore"
+
+// Rule checks jobs for one kind of mistake.
+type Rule struct {
+	// ID names the rule in diagnostics and in IgnoreMeta, like
+	// task-resources.
+	ID       string
+	Severity diag.Severity
+	// Description says what the rule checks, for iogo lint --list-rules.
+	Description string
+	Check       func(job *api.Job, r *Reporter)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Rule{}
+)
+
+// Register adds a rule to the ones Lint runs. IDs have to be unique.
+func Register(rule *Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[rule.ID]; ok {
+		panic(fmt.Sprintf("lint rule %s is registered twice", rule.ID))
+	}
+
+	registry[rule.ID] = rule
+}
+
+// Rules lists the registered rules, sorted by ID.
+func Rules() []*Rule {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	rules := make([]*Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules
+}
+
+// Lint runs every registered rule on job.
+func Lint(job *api.Job) diag.Diagnostics {
+	diags := diag.Diagnostics{}
+
+	for _, rule := range Rules() {
+		r := &Reporter{job: job, rule: rule}
+		rule.Check(job, r)
+		diags = append(diags, r.diags...)
+	}
+
+	return diags
+}
+
+// Reporter collects what a rule finds in one job, leaving out what the
+// meta of the job, group or task suppresses.
+type Reporter struct {
+	job   *api.Job
+	rule  *Rule
+	diags diag.Diagnostics
+}
+
+// Job reports a problem of the job itself, or below it at path.
+func (r *Reporter) Job(message string, path ...string) {
+	r.report(nil, nil, message, path)
+}
+
+// Group reports a problem of group, or below it at path.
+func (r *Reporter) Group(group *api.TaskGroup, message string, path ...string) {
+	r.report(group, nil, message, path)
+}
+
+// Task reports a problem of task, or below it at path.
+func (r *Reporter) Task(group *api.TaskGroup, task *api.Task, message string, path ...string) {
+	r.report(group, task, message, path)
+}
+
+func (r *Reporter) report(group *api.TaskGroup, task *api.Task, message string, path []string) {
+	name := stringOf(r.job.Name)
+	if name == "" {
+		name = stringOf(r.job.ID)
+	}
+
+	segments := []string{fmt.Sprintf("job[%q]", name)}
+	metas := []map[string]string{r.job.Meta}
+
+	if group != nil {
+		segments = append(segments, fmt.Sprintf("group[%q]", stringOf(group.Name)))
+		metas = append(metas, group.Meta)
+	}
+
+	if task != nil {
+		segments = append(segments, fmt.Sprintf("task[%q]", task.Name))
+		metas = append(metas, task.Meta)
+	}
+
+	for _, meta := range metas {
+		if ignores(meta, r.rule.ID) {
+			return
+		}
+	}
+
+	r.diags = append(r.diags, &diag.Diagnostic{
+		Severity: r.rule.Severity,
+		Source:   "lint",
+		Rule:     r.rule.ID,
+		Path:     strings.Join(append(segments, path...), "."),
+		Message:  message,
+	})
+}
+
+// ignores is true if meta suppresses the rule with id.
+func ignores(meta map[string]string, id string) bool {
+	for _, ignored := range strings.Split(meta[IgnoreMeta], ",") {
+		if ignored = strings.TrimSpace(ignored); ignored == id || ignored == "all" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,94 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("web"),
+		TaskGroups: []*api.TaskGroup{
+			{
+				Name:  ptrStr("api"),
+				Count: ptrInt(0),
+				Networks: []*api.NetworkResource{
+					{DynamicPorts: []api.Port{{Label: "http"}}},
+				},
+				Services: []*api.Service{
+					{PortLabel: "http", Checks: []api.ServiceCheck{{Type: "http"}}},
+					{PortLabel: "metrics"},
+					{PortLabel: "8080"},
+				},
+				Tasks: []*api.Task{
+					{
+						Name:      "server",
+						Resources: &api.Resources{CPU: ptrInt(100)},
+						Vault:     &api.Vault{},
+						Templates: []*api.Template{
+							{DestPath: ptrStr("local/config.json")},
+							{DestPath: ptrStr("${NOMAD_SECRETS_DIR}/env")},
+							{DestPath: ptrStr("/etc/config")},
+							{DestPath: ptrStr("local/../../alloc/x")},
+						},
+					},
+					{Name: "server"},
+				},
+			},
+			{Name: ptrStr("api"), Tasks: []*api.Task{{Name: "db"}}},
+		},
+	}
+
+	r.Equal(`error: lint duplicate-name: job["web"].group["api"].task["server"]: is defined more than once
+error: lint duplicate-name: job["web"].group["api"]: is defined more than once
+error: lint http-check-path: job["web"].group["api"].service[0].check[0]: http check has no path
+warning: lint service-count-zero: job["web"].group["api"]: has count 0, so it never runs
+error: lint service-port: job["web"].group["api"].service[1]: uses port "metrics", which isn't defined in a network block
+warning: lint task-resources: job["web"].group["api"].task["server"]: has no cpu or memory resources
+warning: lint task-resources: job["web"].group["api"].task["db"]: has no cpu or memory resources
+warning: lint template-destination: job["web"].group["api"].task["server"].template[2]: writes to "/etc/config", outside of local/ and secrets/
+warning: lint template-destination: job["web"].group["api"].task["server"].template[3]: writes to "local/../../alloc/x", outside of local/ and secrets/
+error: lint vault-policies: job["web"].group["api"].task["server"].vault: has no policies`, Lint(job).Error())
+
+	job.Type = ptrStr("batch")
+	job.Meta = map[string]string{IgnoreMeta: "duplicate-name, template-destination"}
+	job.TaskGroups[0].Meta = map[string]string{IgnoreMeta: "all"}
+	r.Equal(`warning: lint task-resources: job["web"].group["api"].task["db"]: has no cpu or memory resources`, Lint(job).Error())
+
+	job.TaskGroups[1].Tasks[0].Meta = map[string]string{IgnoreMeta: "task-resources"}
+	r.Empty(Lint(job))
+}
+
+func TestRegister(t *testing.T) {
+	r := require.New(t)
+
+	ids := []string{}
+	for _, rule := range Rules() {
+		ids = append(ids, rule.ID)
+	}
+	r.Equal([]string{
+		"duplicate-name",
+		"http-check-path",
+		"service-count-zero",
+		"service-port",
+		"task-resources",
+		"template-destination",
+		"vault-policies",
+	}, ids)
+
+	r.Panics(func() {
+		Register(&Rule{ID: "task-resources"})
+	})
+}
+
+func ptrStr(v string) *string {
+	return &v
+}
+
+func ptrInt(v int) *int {
+	return &v
+}
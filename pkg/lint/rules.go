@@ -0,0 +1,230 @@
+package lint
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+func init() {
+	Register(&Rule{
+		ID:          "task-resources",
+		Severity:    diag.SeverityWarning,
+		Description: "tasks should ask for cpu and memory instead of getting Nomad's defaults",
+		Check:       checkTaskResources,
+	})
+	Register(&Rule{
+		ID:          "service-port",
+		Severity:    diag.SeverityError,
+		Description: "services and checks can only use port labels that the group or task defines",
+		Check:       checkServicePorts,
+	})
+	Register(&Rule{
+		ID:          "http-check-path",
+		Severity:    diag.SeverityError,
+		Description: "http checks need a path",
+		Check:       checkHttpCheckPath,
+	})
+	Register(&Rule{
+		ID:          "service-count-zero",
+		Severity:    diag.SeverityWarning,
+		Description: "groups of service jobs with count 0 never run",
+		Check:       checkServiceCountZero,
+	})
+	Register(&Rule{
+		ID:          "duplicate-name",
+		Severity:    diag.SeverityError,
+		Description: "groups of a job and tasks of a group need unique names",
+		Check:       checkDuplicateNames,
+	})
+	Register(&Rule{
+		ID:          "vault-policies",
+		Severity:    diag.SeverityError,
+		Description: "vault blocks need at least one policy",
+		Check:       checkVaultPolicies,
+	})
+	Register(&Rule{
+		ID:          "template-destination",
+		Severity:    diag.SeverityWarning,
+		Description: "templates should be written below local/ or secrets/ of the task",
+		Check:       checkTemplateDestination,
+	})
+}
+
+func checkTaskResources(job *api.Job, r *Reporter) {
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			resources := task.Resources
+			if resources == nil || (isZero(resources.CPU) && isZero(resources.MemoryMB)) {
+				r.Task(group, task, "has no cpu or memory resources")
+			}
+		}
+	}
+}
+
+func checkServicePorts(job *api.Job, r *Reporter) {
+	for _, group := range job.TaskGroups {
+		groupPorts := portLabels(group.Networks)
+
+		for i, service := range group.Services {
+			checkServicePort(r, group, nil, groupPorts, service, fmt.Sprintf("service[%d]", i))
+		}
+
+		for _, task := range group.Tasks {
+			ports := groupPorts
+			if task.Resources != nil && len(task.Resources.Networks) > 0 {
+				ports = map[string]bool{}
+				for label := range groupPorts {
+					ports[label] = true
+				}
+				for label := range portLabels(task.Resources.Networks) {
+					ports[label] = true
+				}
+			}
+
+			for i, service := range task.Services {
+				checkServicePort(r, group, task, ports, service, fmt.Sprintf("service[%d]", i))
+			}
+		}
+	}
+}
+
+func checkServicePort(r *Reporter, group *api.TaskGroup, task *api.Task, ports map[string]bool, service *api.Service, path string) {
+	if isUndefinedPort(ports, service.PortLabel) {
+		r.report(group, task, fmt.Sprintf("uses port %q, which isn't defined in a network block", service.PortLabel), []string{path})
+	}
+
+	for i, check := range service.Checks {
+		if isUndefinedPort(ports, check.PortLabel) {
+			r.report(group, task, fmt.Sprintf("uses port %q, which isn't defined in a network block", check.PortLabel), []string{path, fmt.Sprintf("check[%d]", i)})
+		}
+	}
+}
+
+// isUndefinedPort is true for labels that aren't in ports. Numbers are
+// ports rather than labels, and are always fine.
+func isUndefinedPort(ports map[string]bool, label string) bool {
+	if label == "" || ports[label] {
+		return false
+	}
+
+	_, err := strconv.Atoi(label)
+	return err != nil
+}
+
+func portLabels(networks []*api.NetworkResource) map[string]bool {
+	labels := map[string]bool{}
+	for _, network := range networks {
+		for _, port := range network.ReservedPorts {
+			labels[port.Label] = true
+		}
+		for _, port := range network.DynamicPorts {
+			labels[port.Label] = true
+		}
+	}
+
+	return labels
+}
+
+func checkHttpCheckPath(job *api.Job, r *Reporter) {
+	each := func(group *api.TaskGroup, task *api.Task, services []*api.Service) {
+		for i, service := range services {
+			for j, check := range service.Checks {
+				if strings.EqualFold(check.Type, "http") && check.Path == "" {
+					r.report(group, task, "http check has no path", []string{fmt.Sprintf("service[%d]", i), fmt.Sprintf("check[%d]", j)})
+				}
+			}
+		}
+	}
+
+	for _, group := range job.TaskGroups {
+		each(group, nil, group.Services)
+		for _, task := range group.Tasks {
+			each(group, task, task.Services)
+		}
+	}
+}
+
+func checkServiceCountZero(job *api.Job, r *Reporter) {
+	// jobs without a type are service jobs
+	if job.Type != nil && *job.Type != "" && *job.Type != "service" {
+		return
+	}
+
+	for _, group := range job.TaskGroups {
+		if group.Count != nil && *group.Count == 0 {
+			r.Group(group, "has count 0, so it never runs")
+		}
+	}
+}
+
+func checkDuplicateNames(job *api.Job, r *Reporter) {
+	groups := map[string]bool{}
+
+	for _, group := range job.TaskGroups {
+		name := stringOf(group.Name)
+		if groups[name] {
+			r.Group(group, "is defined more than once")
+		}
+		groups[name] = true
+
+		tasks := map[string]bool{}
+		for _, task := range group.Tasks {
+			if tasks[task.Name] {
+				r.Task(group, task, "is defined more than once")
+			}
+			tasks[task.Name] = true
+		}
+	}
+}
+
+func checkVaultPolicies(job *api.Job, r *Reporter) {
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if task.Vault != nil && len(task.Vault.Policies) == 0 {
+				r.Task(group, task, "has no policies", "vault")
+			}
+		}
+	}
+}
+
+// taskDirs are the directories of a task that templates may write to, and
+// the variables that point to them.
+var taskDirs = map[string]string{
+	"${NOMAD_TASK_DIR}":    "local",
+	"${NOMAD_SECRETS_DIR}": "secrets",
+}
+
+func checkTemplateDestination(job *api.Job, r *Reporter) {
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			for i, template := range task.Templates {
+				if template.DestPath == nil || isTaskDir(*template.DestPath) {
+					continue
+				}
+
+				r.Task(group, task, fmt.Sprintf("writes to %q, outside of local/ and secrets/", *template.DestPath), fmt.Sprintf("template[%d]", i))
+			}
+		}
+	}
+}
+
+func isTaskDir(destination string) bool {
+	for variable, dir := range taskDirs {
+		if strings.HasPrefix(destination, variable+"/") {
+			destination = dir + strings.TrimPrefix(destination, variable)
+		}
+	}
+
+	cleaned := path.Clean(destination)
+
+	return strings.HasPrefix(cleaned, "local/") || strings.HasPrefix(cleaned, "secrets/")
+}
+
+func isZero(i *int) bool {
+	return i == nil || *i == 0
+}
@@ -0,0 +1,416 @@
+// Package login obtains the tokens needed to work with a bitte cluster,
+// caching them for a month. It never changes the environment of the calling
+// process; the tokens are returned instead.
+package login
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jdxcode/netrc"
+)
+
+const githubApi = "api.github.com"
+
+// Options for Login.
+type Options struct {
+	Cluster string
+	// CacheDir is where tokens are cached, CacheDir(Cluster) if empty.
+	CacheDir string
+	// Force grabs fresh tokens, ignoring the cache.
+	Force bool
+	// AWS also obtains AWS keys.
+	AWS bool
+	// GithubToken is read from ~/.netrc if empty.
+	GithubToken string
+	// Logger receives progress messages, they are discarded if nil.
+	Logger *log.Logger
+}
+
+// Credentials are the tokens obtained by Login.
+type Credentials struct {
+	GithubToken        string
+	VaultToken         string
+	NomadToken         string
+	ConsulToken        string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+}
+
+// Environ returns the credentials as environment variables, for example to
+// use them in exec.Cmd.Env. Empty credentials are left out.
+func (c *Credentials) Environ() []string {
+	env := []string{}
+
+	for _, pair := range [][2]string{
+		{"GITHUB_TOKEN", c.GithubToken},
+		{"VAULT_TOKEN", c.VaultToken},
+		{"NOMAD_TOKEN", c.NomadToken},
+		{"CONSUL_HTTP_TOKEN", c.ConsulToken},
+		{"AWS_ACCESS_KEY_ID", c.AWSAccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", c.AWSSecretAccessKey},
+	} {
+		if pair[1] != "" {
+			env = append(env, pair[0]+"="+pair[1])
+		}
+	}
+
+	return env
+}
+
+// Login obtains a Vault token using the GitHub token, and Nomad, Consul and
+// optionally AWS credentials using Vault. Failing to get any of the latter
+// is logged, but doesn't fail the login.
+func Login(ctx context.Context, opts Options) (*Credentials, error) {
+	l := &login{ctx: ctx, opts: opts, creds: &Credentials{}}
+
+	l.cacheDir = opts.CacheDir
+	if l.cacheDir == "" {
+		l.cacheDir = CacheDir(opts.Cluster)
+	}
+
+	if opts.Logger == nil {
+		l.opts.Logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	if err := os.MkdirAll(l.cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	if opts.GithubToken != "" {
+		l.creds.GithubToken = opts.GithubToken
+	} else if err := l.setGithubToken(); err != nil {
+		return nil, err
+	}
+
+	if err := l.loginVault(); err != nil {
+		return nil, err
+	}
+
+	admin, err := l.isAdmin()
+	if err != nil {
+		return nil, err
+	}
+
+	wg := &sync.WaitGroup{}
+
+	if admin {
+		l.role = "admin"
+	} else {
+		l.role = "developer"
+	}
+
+	if opts.AWS {
+		wg.Add(1)
+		go l.loginAWS(wg)
+	}
+
+	wg.Add(2)
+	go l.loginNomad(wg)
+	go l.loginConsul(wg)
+	wg.Wait()
+
+	return l.creds, nil
+}
+
+// CacheDir is where the tokens of a cluster are cached by default.
+func CacheDir(cluster string) string {
+	root := os.Getenv("XDG_CACHE_HOME")
+	if root == "" {
+		root = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+
+	return filepath.Join(root, "bitte", cluster, "tokens")
+}
+
+type login struct {
+	ctx      context.Context
+	opts     Options
+	cacheDir string
+	role     string
+	// each login writes only its own fields, so they can run in parallel
+	creds *Credentials
+}
+
+func (l *login) isNotExpired(tokenPath string) bool {
+	if l.opts.Force {
+		return false
+	}
+
+	fileStat, err := os.Stat(tokenPath)
+
+	if err != nil {
+		return false
+	}
+
+	return fileStat.ModTime().After(time.Now().AddDate(0, -1, 0))
+}
+
+// vault runs the vault command with the Vault token obtained so far.
+func (l *login) vault(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(l.ctx, "vault", args...)
+	cmd.Env = os.Environ()
+	if l.creds.VaultToken != "" {
+		cmd.Env = append(cmd.Env, "VAULT_TOKEN="+l.creds.VaultToken)
+	}
+
+	return cmd
+}
+
+func (l *login) setGithubToken() error {
+	usr, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	if rc, err := netrc.Parse(filepath.Join(usr.HomeDir, ".netrc")); err != nil {
+		return err
+	} else if machine := rc.Machine(githubApi); machine != nil {
+		if password := machine.Get("password"); password != "" {
+			l.creds.GithubToken = password
+
+			return nil
+		} else {
+			return fmt.Errorf("No password for %s found in ~/.netrc", githubApi)
+		}
+	}
+
+	return fmt.Errorf("No entry for %s found in ~/.netrc", githubApi)
+}
+
+func (l *login) loginVault() error {
+	tokenPath := filepath.Join(l.cacheDir, "vault.token")
+	content, err := os.ReadFile(tokenPath)
+	if err == nil {
+		l.creds.VaultToken = string(content)
+	}
+
+	if l.isNotExpired(tokenPath) {
+		return nil
+	}
+
+	l.opts.Logger.Println("Obtaining and caching Vault token")
+
+	cmd := l.vault(
+		"login",
+		"-no-store",
+		"-token-only",
+		"-method=github",
+		"-path=github-employees",
+		"token="+l.creds.GithubToken)
+
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		l.opts.Logger.Println(stdout.String())
+		return err
+	}
+
+	token := stdout.String()
+	l.creds.VaultToken = token
+
+	if err := os.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+		return err
+	}
+
+	out, err := l.vault("token", "lookup").CombinedOutput()
+	if err != nil {
+		l.opts.Logger.Println(string(out))
+		return err
+	}
+
+	return nil
+}
+
+func (l *login) loginAWS(wg *sync.WaitGroup) {
+	defer wg.Done()
+	if err := l.loginAWSInner(); err != nil {
+		l.opts.Logger.Println("Failed logging into AWS:", err.Error())
+	}
+}
+
+func (l *login) loginAWSInner() error {
+	keyPath := filepath.Join(l.cacheDir, "aws.key")
+	secretPath := filepath.Join(l.cacheDir, "aws.secret")
+
+	if key, err := os.ReadFile(keyPath); err == nil {
+		l.creds.AWSAccessKeyID = string(key)
+	}
+
+	if secret, err := os.ReadFile(secretPath); err == nil {
+		l.creds.AWSSecretAccessKey = string(secret)
+	}
+
+	if l.isNotExpired(keyPath) && l.isNotExpired(secretPath) {
+		return nil
+	}
+
+	l.opts.Logger.Println("Obtaining and caching AWS keys")
+
+	credsPath := fmt.Sprintf("aws/creds/%s", l.role)
+
+	cmd := l.vault("read", credsPath, "-format=json")
+	// don't let a local AWS profile get in the way
+	cmd.Env = append(cmd.Env, "AWS_PROFILE=", "AWS_SHARED_CREDENTIALS_FILE=/dev/null")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		l.opts.Logger.Println("failed `vault read ", credsPath, " -format=json`:", string(output))
+		return err
+	}
+
+	Keys := &AWSKeys{}
+
+	if err := json.Unmarshal(output, Keys); err != nil {
+		return err
+	}
+
+	key := Keys.Data.Access_Key
+	l.creds.AWSAccessKeyID = key
+	if err := os.WriteFile(keyPath, []byte(key), 0600); err != nil {
+		return err
+	}
+
+	secret := Keys.Data.Secret_Key
+	l.creds.AWSSecretAccessKey = secret
+	if err := os.WriteFile(secretPath, []byte(secret), 0600); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *login) loginConsul(wg *sync.WaitGroup) {
+	defer wg.Done()
+	if err := l.loginConsulInner(); err != nil {
+		l.opts.Logger.Println("Failed logging into Consul:", err.Error())
+	}
+}
+
+func (l *login) loginConsulInner() error {
+	tokenPath := filepath.Join(l.cacheDir, "consul.token")
+	cachedContent, err := os.ReadFile(tokenPath)
+	if err == nil {
+		l.creds.ConsulToken = string(cachedContent)
+	}
+
+	if l.isNotExpired(tokenPath) {
+		return nil
+	}
+
+	l.opts.Logger.Println("Obtaining and caching Consul token in " + tokenPath)
+
+	output, err := l.vault("read", "-field", "token", "consul/creds/"+l.role).CombinedOutput()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(tokenPath, output, 0600); err != nil {
+		return err
+	}
+
+	l.creds.ConsulToken = string(output)
+
+	return nil
+}
+
+func (l *login) loginNomad(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if err := l.loginNomadInner(); err != nil {
+		l.opts.Logger.Println("Failed logging into Nomad:", err.Error())
+	}
+}
+
+func (l *login) loginNomadInner() error {
+	tokenPath := filepath.Join(l.cacheDir, "nomad.token")
+	cachedContent, err := os.ReadFile(tokenPath)
+	if err == nil {
+		l.creds.NomadToken = string(cachedContent)
+	}
+
+	if l.isNotExpired(tokenPath) {
+		return nil
+	}
+
+	l.opts.Logger.Println("Obtaining and caching Nomad token")
+
+	output, err := l.vault("read", "-field", "secret_id", "nomad/creds/"+l.role).CombinedOutput()
+	if err != nil {
+		l.opts.Logger.Println(string(output))
+		return err
+	}
+
+	if err := os.WriteFile(tokenPath, output, 0600); err != nil {
+		return err
+	}
+
+	l.creds.NomadToken = string(output)
+
+	return nil
+}
+
+type VaultToken struct {
+	Data VaultTokenData
+}
+
+type VaultTokenData struct {
+	Policies []string
+}
+
+type AWSKeys struct {
+	Data AWSKeysData
+}
+
+type AWSKeysData struct {
+	Access_Key string
+	Secret_Key string
+}
+
+func (l *login) isAdmin() (bool, error) {
+	tokenPath := filepath.Join(l.cacheDir, "vault.token")
+	policyPath := filepath.Join(l.cacheDir, "vault.policy")
+
+	if l.isNotExpired(tokenPath) {
+		content, err := os.ReadFile(policyPath)
+		if string(content) == "admin" {
+			return true, err
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+
+	output, err := l.vault("token", "lookup", "-format", "json").CombinedOutput()
+	if err != nil {
+		return false, err
+	}
+
+	vaultToken := &VaultToken{}
+	if err := json.Unmarshal(output, vaultToken); err != nil {
+		return false, err
+	}
+
+	for _, policy := range vaultToken.Data.Policies {
+		if policy == "admin" {
+			err := os.WriteFile(policyPath, []byte(policy), 0600)
+			return true, err
+		}
+	}
+
+	err = os.WriteFile(policyPath, []byte("developer"), 0600)
+	return false, err
+}
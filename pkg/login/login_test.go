@@ -0,0 +1,25 @@
+package login
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialsEnviron(t *testing.T) {
+	r := require.New(t)
+
+	creds := &Credentials{VaultToken: "s.vault", NomadToken: "nomad"}
+	r.Equal([]string{"VAULT_TOKEN=s.vault", "NOMAD_TOKEN=nomad"}, creds.Environ())
+}
+
+func TestCacheDir(t *testing.T) {
+	r := require.New(t)
+
+	old := os.Getenv("XDG_CACHE_HOME")
+	defer os.Setenv("XDG_CACHE_HOME", old)
+
+	r.Nil(os.Setenv("XDG_CACHE_HOME", "/tmp/cache"))
+	r.Equal("/tmp/cache/bitte/mainnet/tokens", CacheDir("mainnet"))
+}
@@ -0,0 +1,355 @@
+// Package redact masks secret values in jobs, and in whatever is printed
+// about them, before they reach a terminal.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// Mask replaces every secret value.
+const Mask = "<redacted>"
+
+// DefaultPatterns are the parts of key names that mark their values as
+// secret.
+var DefaultPatterns = []string{"TOKEN", "SECRET", "PASSWORD", "KEY"}
+
+// minSecretLength keeps short values, like a port or a 1, from being masked
+// all over the output just because a secret key happens to hold them.
+const minSecretLength = 4
+
+// Rules decide which keys hold secrets. Keys are compared ignoring case.
+type Rules struct {
+	// Patterns are parts of secret key names, DefaultPatterns if nil.
+	Patterns []string `json:"patterns,omitempty"`
+	// Allow lists keys that match a pattern but aren't secret.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists secret keys that match no pattern.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// IsSecret is true if the value of key is secret.
+func (r Rules) IsSecret(key string) bool {
+	for _, deny := range r.Deny {
+		if strings.EqualFold(deny, key) {
+			return true
+		}
+	}
+
+	for _, allow := range r.Allow {
+		if strings.EqualFold(allow, key) {
+			return false
+		}
+	}
+
+	patterns := r.Patterns
+	if patterns == nil {
+		patterns = DefaultPatterns
+	}
+
+	upper := strings.ToUpper(key)
+	for _, pattern := range patterns {
+		if strings.Contains(upper, strings.ToUpper(pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redactor masks secret values. It remembers the secrets of every job it
+// sees, so it can mask them anywhere else as well, like in the diff of
+// nomad job plan.
+type Redactor struct {
+	mu      sync.Mutex
+	rules   Rules
+	reveal  bool
+	secrets map[string]bool
+}
+
+func New(rules Rules) *Redactor {
+	return &Redactor{rules: rules, secrets: map[string]bool{}}
+}
+
+// SetRules replaces the rules for jobs seen from now on.
+func (r *Redactor) SetRules(rules Rules) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = rules
+}
+
+// Deny adds keys to the Deny rules.
+func (r *Redactor) Deny(keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules.Deny = append(r.rules.Deny, keys...)
+}
+
+// SetReveal turns masking off, or back on.
+func (r *Redactor) SetReveal(reveal bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reveal = reveal
+}
+
+// Revealed is true if masking is off.
+func (r *Redactor) Revealed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reveal
+}
+
+// Job returns a copy of job with its secrets masked, or job itself if
+// masking is off. Secrets are in env, meta, task config, artifact options
+// and headers, and in assignments like TOKEN=... in templates.
+func (r *Redactor) Job(job *api.Job) (*api.Job, error) {
+	if r.Revealed() {
+		return job, nil
+	}
+
+	return r.mask(job)
+}
+
+// Learn remembers the secrets of job without masking it, for jobs that go
+// to Nomad as they are.
+func (r *Redactor) Learn(job *api.Job) error {
+	_, err := r.mask(job)
+	return err
+}
+
+func (r *Redactor) mask(job *api.Job) (*api.Job, error) {
+	src, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	masked := &api.Job{}
+	if err := json.Unmarshal(src, masked); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maskStrings(masked.Meta)
+
+	for _, group := range masked.TaskGroups {
+		r.maskStrings(group.Meta)
+		r.maskServices(group.Services)
+
+		for _, task := range group.Tasks {
+			r.maskStrings(task.Env)
+			r.maskStrings(task.Meta)
+			r.maskConfig(task.Config)
+			r.maskServices(task.Services)
+
+			for _, artifact := range task.Artifacts {
+				r.maskStrings(artifact.GetterOptions)
+				r.maskStrings(artifact.GetterHeaders)
+			}
+
+			for _, template := range task.Templates {
+				if template.EmbeddedTmpl != nil {
+					data := r.maskAssignments(*template.EmbeddedTmpl)
+					template.EmbeddedTmpl = &data
+				}
+			}
+		}
+	}
+
+	return masked, nil
+}
+
+func (r *Redactor) maskServices(services []*api.Service) {
+	for _, service := range services {
+		if service.Connect == nil || service.Connect.SidecarTask == nil {
+			continue
+		}
+
+		r.maskStrings(service.Connect.SidecarTask.Env)
+		r.maskStrings(service.Connect.SidecarTask.Meta)
+		r.maskConfig(service.Connect.SidecarTask.Config)
+	}
+}
+
+func (r *Redactor) maskStrings(m map[string]string) {
+	for k, v := range m {
+		if v != "" && r.rules.IsSecret(k) {
+			r.remember(v)
+			m[k] = Mask
+		}
+	}
+}
+
+// maskConfig masks the values of secret keys, and everything below them
+// if they hold a block.
+func (r *Redactor) maskConfig(config map[string]interface{}) {
+	for k, v := range config {
+		if r.rules.IsSecret(k) {
+			config[k] = r.maskAll(v)
+		} else {
+			config[k] = r.maskNested(v)
+		}
+	}
+}
+
+func (r *Redactor) maskNested(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		r.maskConfig(value)
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = r.maskNested(elem)
+		}
+	}
+
+	return v
+}
+
+func (r *Redactor) maskAll(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		if value == "" {
+			return value
+		}
+		r.remember(value)
+		return Mask
+	case map[string]interface{}:
+		for k, elem := range value {
+			value[k] = r.maskAll(elem)
+		}
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = r.maskAll(elem)
+		}
+	}
+
+	return v
+}
+
+// assignment matches lines like `export DB_PASSWORD="..."` or
+// `api_key: ...` in templates.
+var assignment = regexp.MustCompile(`^(\s*(?:export\s+)?["']?([A-Za-z0-9_.\-]+)["']?\s*[=:]\s*)(.*?)\s*$`)
+
+// maskAssignments masks the values assigned to secret keys in a template.
+// Values that are rendered by the template itself, like a secret read from
+// Vault, are left alone since they aren't in the job.
+func (r *Redactor) maskAssignments(data string) string {
+	lines := strings.Split(data, "\n")
+
+	for i, line := range lines {
+		match := assignment.FindStringSubmatch(line)
+		if match == nil || match[3] == "" || strings.Contains(match[3], "{{") || !r.rules.IsSecret(match[2]) {
+			continue
+		}
+
+		r.remember(strings.Trim(match[3], `"'`))
+		lines[i] = match[1] + Mask
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (r *Redactor) remember(secret string) {
+	for _, line := range strings.Split(secret, "\n") {
+		if line = strings.TrimSpace(line); len(line) >= minSecretLength {
+			r.secrets[line] = true
+		}
+	}
+}
+
+// textAssignment matches `key=value`, `"key": "value"` or
+// `Env[key]: "value"` in free text, like logs and the output of nomad. Values
+// after a colon have to be quoted, or every message about a key would be
+// masked.
+var textAssignment = regexp.MustCompile(`([A-Za-z0-9_.\-]+)(\]?"?\s*=\s*"?|\]?"?\s*:\s*")([^"\s,]+)`)
+
+// String masks every secret it has seen in s, and the values assigned to
+// secret keys.
+func (r *Redactor) String(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reveal {
+		return s
+	}
+
+	secrets := make([]string, 0, len(r.secrets))
+	for secret := range r.secrets {
+		secrets = append(secrets, secret)
+	}
+
+	// longer secrets first, in case one contains another
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+
+	return textAssignment.ReplaceAllStringFunc(s, func(match string) string {
+		parts := textAssignment.FindStringSubmatch(match)
+		if parts[3] == Mask || !r.rules.IsSecret(parts[1]) {
+			return match
+		}
+
+		return parts[1] + parts[2] + Mask
+	})
+}
+
+// Writer masks secrets in everything written to it, one line at a time so
+// a secret can't slip through split across two writes.
+type Writer struct {
+	redactor *Redactor
+	out      io.Writer
+	buf      []byte
+}
+
+// Writer returns a Writer that masks secrets on their way to out.
+func (r *Redactor) Writer(out io.Writer) *Writer {
+	return &Writer{redactor: r, out: out}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	if _, err := io.WriteString(w.out, w.redactor.String(string(w.buf[:i+1]))); err != nil {
+		return 0, err
+	}
+
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+
+	return len(p), nil
+}
+
+// Flush writes what is left after the last line.
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(w.out, w.redactor.String(string(w.buf)))
+	w.buf = w.buf[:0]
+
+	return err
+}
@@ -0,0 +1,102 @@
+package redact
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+func ptrStr(v string) *string {
+	return &v
+}
+
+func TestRules(t *testing.T) {
+	r := require.New(t)
+
+	rules := Rules{Allow: []string{"KEYBOARD_LAYOUT"}, Deny: []string{"DATABASE_URL"}}
+
+	r.True(rules.IsSecret("NOMAD_TOKEN"))
+	r.True(rules.IsSecret("aws_secret_access_key"))
+	r.True(rules.IsSecret("Password"))
+	r.True(rules.IsSecret("database_url"))
+	r.False(rules.IsSecret("keyboard_layout"))
+	r.False(rules.IsSecret("PORT"))
+
+	r.False(Rules{Patterns: []string{"PASS"}}.IsSecret("NOMAD_TOKEN"))
+}
+
+func TestRedactJob(t *testing.T) {
+	r := require.New(t)
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		Meta: map[string]string{"owner": "ops"},
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("docs"),
+			Tasks: []*api.Task{{
+				Name: "server",
+				Env:  map[string]string{"GITHUB_TOKEN": "ghp_0123456789", "PORT": "8080"},
+				Config: map[string]interface{}{
+					"image": "nginx",
+					"auth":  []interface{}{map[string]interface{}{"username": "docs", "password": "hunter22"}},
+				},
+				Templates: []*api.Template{{
+					EmbeddedTmpl: ptrStr("PORT=8080\nexport API_KEY=\"s3cr3t-key\"\nVAULT_TOKEN={{ with secret \"kv/docs\" }}{{ .Data.token }}{{ end }}\n"),
+				}},
+			}},
+		}},
+	}
+
+	redactor := New(Rules{})
+	masked, err := redactor.Job(job)
+	r.Nil(err)
+
+	task := masked.TaskGroups[0].Tasks[0]
+	r.Equal(map[string]string{"GITHUB_TOKEN": Mask, "PORT": "8080"}, task.Env)
+	r.Equal([]interface{}{map[string]interface{}{"username": "docs", "password": Mask}}, task.Config["auth"])
+	r.Equal("nginx", task.Config["image"])
+	r.Equal("PORT=8080\nexport API_KEY="+Mask+"\nVAULT_TOKEN={{ with secret \"kv/docs\" }}{{ .Data.token }}{{ end }}\n", *task.Templates[0].EmbeddedTmpl)
+	r.Equal(map[string]string{"owner": "ops"}, masked.Meta)
+
+	// the original is left alone
+	r.Equal("ghp_0123456789", job.TaskGroups[0].Tasks[0].Env["GITHUB_TOKEN"])
+
+	// and its secrets are masked anywhere else
+	r.Equal("+/- Env[GITHUB_TOKEN]: \""+Mask+"\" => \""+Mask+"\"", redactor.String(`+/- Env[GITHUB_TOKEN]: "ghp_old" => "ghp_0123456789"`))
+	r.Equal("login with "+Mask+" as docs", redactor.String("login with hunter22 as docs"))
+	r.Equal("export NOMAD_TOKEN="+Mask, redactor.String("export NOMAD_TOKEN=abcd-1234"))
+	r.Equal("config.api_key: unknown config key", redactor.String("config.api_key: unknown config key"))
+
+	redactor.SetReveal(true)
+	revealed, err := redactor.Job(job)
+	r.Nil(err)
+	r.Equal(job, revealed)
+	r.Equal("login with hunter22 as docs", redactor.String("login with hunter22 as docs"))
+}
+
+func TestWriter(t *testing.T) {
+	r := require.New(t)
+
+	redactor := New(Rules{})
+	r.Nil(redactor.Learn(&api.Job{
+		TaskGroups: []*api.TaskGroup{{
+			Tasks: []*api.Task{{Env: map[string]string{"SECRET": "split-secret"}}},
+		}},
+	}))
+
+	out := &bytes.Buffer{}
+	w := redactor.Writer(out)
+
+	_, err := w.Write([]byte("value split-"))
+	r.Nil(err)
+	r.Equal("", out.String())
+
+	_, err = w.Write([]byte("secret\nand split-sec"))
+	r.Nil(err)
+	r.Equal("value "+Mask+"\n", out.String())
+
+	r.Nil(w.Flush())
+	r.Equal("value "+Mask+"\nand split-sec", out.String())
+}
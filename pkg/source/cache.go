@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ExportCacheDir is where exports are cached by default.
+func ExportCacheDir() string {
+	root := os.Getenv("XDG_CACHE_HOME")
+	if root == "" {
+		root = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+
+	return filepath.Join(root, "bitte", "iogo", "exports")
+}
+
+// ExportCache keeps the output of cue export, keyed by a hash of everything
+// that goes into it, so unchanged sources are neither vetted nor exported
+// again.
+type ExportCache struct {
+	Dir string
+}
+
+func (c *ExportCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+func (c *ExportCache) get(key string) ([]byte, bool) {
+	output, err := ioutil.ReadFile(c.path(key))
+	return output, err == nil
+}
+
+// put writes to a temporary file first, so a concurrent iogo never reads
+// half an export.
+func (c *ExportCache) put(key string, output []byte) error {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(output); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// Clear removes every cached export.
+func (c *ExportCache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// cacheKey hashes the .cue files and cue.mod of the module, the version of
+// cue, the tags and the arguments of the export.
+func (c *Cue) cacheKey(ctx context.Context, args []string) (string, error) {
+	dir := c.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	inputs, err := HashInputs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := c.run(ctx, "version")
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", inputs, version)
+	for _, tag := range c.Tags {
+		fmt.Fprintf(h, "tag\x00%s\x00", tag)
+	}
+	for _, arg := range args {
+		fmt.Fprintf(h, "arg\x00%s\x00", arg)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,260 @@
+// Package source loads Nomad jobs from CUE, Nix and JSON dumps of jobs.
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+type JobWrapper struct {
+	Job *api.Job
+}
+
+type CueExport struct {
+	Rendered map[string]map[string]JobWrapper
+}
+
+// Job looks up a job in the export.
+func (e *CueExport) Job(namespace, job string) (*api.Job, error) {
+	if foundNamespace, ok := e.Rendered[namespace]; ok {
+		if foundJob, ok := foundNamespace[job]; ok {
+			return foundJob.Job, nil
+		} else {
+			return nil, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+		}
+	}
+
+	return nil, fmt.Errorf("Missing namespace %s", namespace)
+}
+
+// Cue loads jobs by running the cue command.
+type Cue struct {
+	// Dir is where cue runs, the working directory if empty.
+	Dir string
+	// Command is the cue binary, cue from the PATH if empty.
+	Command string
+	// Package is the CUE package with the jobs, like bitte. Without one,
+	// cue loads the package in Dir and vets every package below it.
+	Package string
+	// Expression holds the jobs by namespace and name, rendered if empty.
+	Expression string
+	// Tags are injected with -t, like env=prod.
+	Tags []string
+	// Cache keeps exports of unchanged sources, nothing is cached if nil.
+	Cache *ExportCache
+}
+
+// CueError is a cue command that failed, along with what it printed.
+type CueError struct {
+	Command string
+	Output  string
+	Err     error
+	// Diagnostics are the errors parsed from Output.
+	Diagnostics diag.Diagnostics
+}
+
+func (e *CueError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("%s failed: %s", e.Command, e.Err)
+	}
+
+	return fmt.Sprintf("%s failed: %s\n%s", e.Command, e.Err, e.Output)
+}
+
+func (e *CueError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Cue) run(ctx context.Context, args ...string) ([]byte, error) {
+	command := c.Command
+	if command == "" {
+		command = "cue"
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = c.Dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		dir := c.Dir
+		if dir == "" {
+			dir = "."
+		}
+
+		return nil, &CueError{
+			Command:     strings.Join(append([]string{command}, args...), " "),
+			Output:      strings.TrimSpace(string(output)),
+			Err:         err,
+			Diagnostics: ParseCueErrors(dir, "cue "+args[0], string(output)),
+		}
+	}
+
+	return output, nil
+}
+
+// Vet checks every package for concrete values. Anything cue vet prints
+// without failing is returned as info.
+func (c *Cue) Vet(ctx context.Context) (diag.Diagnostics, error) {
+	instance := "./..."
+	if c.Package != "" {
+		instance = ".:" + c.Package
+	}
+
+	output, err := c.run(ctx, append([]string{"vet", "-c"}, c.tagArgs(instance)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if message := string(bytes.TrimSpace(output)); message != "" {
+		return diag.Diagnostics{{Severity: diag.SeverityInfo, Source: "cue vet", Message: message}}, nil
+	}
+
+	return nil, nil
+}
+
+// Export vets and exports all jobs. It is slow for big trees, so it's only
+// meant for listing them, use ExportJob for one job.
+func (c *Cue) Export(ctx context.Context) (*CueExport, diag.Diagnostics, error) {
+	output, diags, err := c.export(ctx, "-e", c.expression())
+	if err != nil {
+		return nil, diags, err
+	}
+
+	export := &CueExport{}
+	err = json.Unmarshal(output, &export.Rendered)
+
+	return export, diags, err
+}
+
+// ExportJob vets and exports only the expression of one job, like
+// rendered["prod"]["web"], which is much faster than exporting all of them.
+func (c *Cue) ExportJob(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error) {
+	output, diags, err := c.export(ctx, "-e", c.jobExpression(namespace, job))
+	if err != nil {
+		return nil, diags, missingJob(err, namespace, job)
+	}
+
+	wrapper := &JobWrapper{}
+	if err := json.Unmarshal(output, wrapper); err != nil {
+		return nil, diags, err
+	}
+
+	if wrapper.Job == nil {
+		return nil, diags, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+	}
+
+	return wrapper.Job, diags, nil
+}
+
+// missingJob turns the undefined field error of cue for the expression of a
+// job into saying whether the namespace or the job is missing.
+func missingJob(err error, namespace, job string) error {
+	var cueErr *CueError
+	if !errors.As(err, &cueErr) || !strings.Contains(cueErr.Output, "undefined field") {
+		return err
+	}
+
+	for _, d := range cueErr.Diagnostics {
+		if !strings.HasPrefix(d.Message, "undefined field") {
+			continue
+		}
+
+		field := strings.Trim(strings.TrimSpace(strings.TrimPrefix(d.Message, "undefined field:")), `"`)
+
+		// the path ends with the field, after the namespace if it's the job
+		segments := strings.Split(d.Path, ".")
+		parent := ""
+		if len(segments) > 1 {
+			parent = strings.Trim(segments[len(segments)-2], `"`)
+		}
+
+		if field == job && (parent == namespace || d.Path == "") {
+			break
+		}
+
+		if field == namespace {
+			return fmt.Errorf("Missing namespace %s", namespace)
+		}
+	}
+
+	return fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+}
+
+// export vets and runs cue export with args, unless the cache already has
+// its output for the same sources.
+func (c *Cue) export(ctx context.Context, args ...string) ([]byte, diag.Diagnostics, error) {
+	if c.Package != "" {
+		args = append(args, ".:"+c.Package)
+	}
+
+	key := ""
+	if c.Cache != nil {
+		var err error
+		if key, err = c.cacheKey(ctx, args); err != nil {
+			return nil, nil, err
+		}
+
+		if output, ok := c.Cache.get(key); ok {
+			return output, nil, nil
+		}
+	}
+
+	diags, err := c.Vet(ctx)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	output, err := c.run(ctx, append([]string{"export"}, c.tagArgs(args...)...)...)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	if c.Cache != nil {
+		if err := c.Cache.put(key, output); err != nil {
+			diags = append(diags, &diag.Diagnostic{
+				Severity: diag.SeverityWarning,
+				Source:   "cache",
+				Message:  fmt.Sprintf("Couldn't cache the export: %s", err),
+			})
+		}
+	}
+
+	return output, diags, nil
+}
+
+// tagArgs puts -t flags for the tags in front of args.
+func (c *Cue) tagArgs(args ...string) []string {
+	tagged := []string{}
+	for _, tag := range c.Tags {
+		tagged = append(tagged, "-t", tag)
+	}
+
+	return append(tagged, args...)
+}
+
+func (c *Cue) expression() string {
+	if c.Expression == "" {
+		return "rendered"
+	}
+
+	return c.Expression
+}
+
+// jobExpression is the CUE expression of one job in the export.
+func (c *Cue) jobExpression(namespace, job string) string {
+	return fmt.Sprintf("%s[%s][%s]", c.expression(), cueString(namespace), cueString(job))
+}
+
+// cueString quotes s as a CUE string, which is the same as a JSON string.
+func cueString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,136 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCue writes a cue command that logs its arguments to calls and prints
+// output, or fails with it if fail is set.
+func fakeCue(t *testing.T, output string, fail bool) (*Cue, string) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+
+	exit := "0"
+	if fail {
+		exit = "1"
+	}
+
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + calls + "\n" +
+		"case \"$1\" in vet) exit 0 ;; version) echo v0.4.0; exit 0 ;; esac\n" +
+		"cat <<'EOF'\n" + output + "\nEOF\n" +
+		"exit " + exit + "\n"
+
+	command := filepath.Join(dir, "cue")
+	require.Nil(t, os.WriteFile(command, []byte(script), 0755))
+
+	return &Cue{Dir: dir, Command: command}, calls
+}
+
+func TestCueExportJob(t *testing.T) {
+	r := require.New(t)
+
+	cue, calls := fakeCue(t, `{"Job": {"Name": "web"}}`, false)
+
+	job, diags, err := cue.ExportJob(context.Background(), "prod", "web")
+	r.Nil(err)
+	r.Empty(diags)
+	r.Equal("web", *job.Name)
+
+	logged, err := os.ReadFile(calls)
+	r.Nil(err)
+	r.Equal([]string{
+		"vet -c ./...",
+		`export -e rendered["prod"]["web"]`,
+	}, strings.Split(strings.TrimSpace(string(logged)), "\n"))
+}
+
+func TestCueExportJobMissing(t *testing.T) {
+	r := require.New(t)
+
+	cue, _ := fakeCue(t, `rendered.prod.nope: undefined field: nope`, true)
+
+	_, _, err := cue.ExportJob(context.Background(), "prod", "nope")
+	r.NotNil(err)
+	r.EqualError(err, "Missing job nope in namespace prod")
+}
+
+func TestCueExportJobMissingNamespace(t *testing.T) {
+	r := require.New(t)
+
+	cue, _ := fakeCue(t, `rendered.staging: undefined field: staging`, true)
+
+	_, _, err := cue.ExportJob(context.Background(), "staging", "web")
+	r.EqualError(err, "Missing namespace staging")
+
+	// a job named like its namespace
+	cue, _ = fakeCue(t, `rendered.web.web: undefined field: web`, true)
+
+	_, _, err = cue.ExportJob(context.Background(), "web", "web")
+	r.EqualError(err, "Missing job web in namespace web")
+
+	cue, _ = fakeCue(t, `rendered."my-ns": undefined field: "my-ns"`, true)
+
+	_, _, err = cue.ExportJob(context.Background(), "my-ns", "web")
+	r.EqualError(err, "Missing namespace my-ns")
+}
+
+func TestCueExportCache(t *testing.T) {
+	r := require.New(t)
+
+	cue, calls := fakeCue(t, `{"Job": {"Name": "web"}}`, false)
+	cue.Cache = &ExportCache{Dir: t.TempDir()}
+
+	source := filepath.Join(cue.Dir, "web.cue")
+	r.Nil(os.WriteFile(source, []byte(`package bitte`), 0644))
+
+	export := func() []string {
+		r.Nil(os.Remove(calls))
+		_, _, err := cue.ExportJob(context.Background(), "prod", "web")
+		r.Nil(err)
+
+		logged, err := os.ReadFile(calls)
+		r.Nil(err)
+		return strings.Split(strings.TrimSpace(string(logged)), "\n")
+	}
+
+	r.Nil(os.WriteFile(calls, nil, 0644))
+	r.Equal([]string{"version", "vet -c ./...", `export -e rendered["prod"]["web"]`}, export())
+	r.Equal([]string{"version"}, export())
+
+	cue.Tags = []string{"env=prod"}
+	r.Equal([]string{"version", "vet -c -t env=prod ./...", `export -t env=prod -e rendered["prod"]["web"]`}, export())
+	r.Equal([]string{"version"}, export())
+
+	r.Nil(os.WriteFile(source, []byte(`package bitte // changed`), 0644))
+	r.Len(export(), 3)
+
+	r.Nil(cue.Cache.Clear())
+	r.Len(export(), 3)
+}
+
+func TestCueExportPackage(t *testing.T) {
+	r := require.New(t)
+
+	cue, calls := fakeCue(t, `{"prod": {"web": {"Job": {"Name": "web"}}}}`, false)
+	cue.Package = "bitte"
+	cue.Expression = "jobs.staging"
+	cue.Tags = []string{"env=staging"}
+
+	export, _, err := cue.Export(context.Background())
+	r.Nil(err)
+	r.Equal("web", *export.Rendered["prod"]["web"].Job.Name)
+
+	logged, err := os.ReadFile(calls)
+	r.Nil(err)
+	r.Equal([]string{
+		"vet -c -t env=staging .:bitte",
+		"export -t env=staging -e jobs.staging .:bitte",
+	}, strings.Split(strings.TrimSpace(string(logged)), "\n"))
+}
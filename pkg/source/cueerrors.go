@@ -0,0 +1,114 @@
+package source
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+var (
+	// cuePositionPattern matches the positions cue lists below an error,
+	// like `    ./jobs/web.cue:12:8`.
+	cuePositionPattern = regexp.MustCompile(`^\s+(\S+):(\d+):(\d+)$`)
+	// cueInlinePattern matches errors with their position in front, like
+	// `jobs/web.cue:20:1: expected '}', found 'EOF'`.
+	cueInlinePattern = regexp.MustCompile(`^(\S+\.cue):(\d+):(\d+): (.*)$`)
+	// cuePathPattern splits the CUE path off an error, like
+	// `rendered.prod.web.Job.Datacenters: conflicting values`.
+	cuePathPattern = regexp.MustCompile(`^([A-Za-z0-9_#$."\-\[\]]+): (.+)$`)
+)
+
+// ParseCueErrors turns what a failed cue command printed into one error
+// diagnostic per message and position, with the line of the source file in
+// dir that it points to. Messages without a position keep only their text.
+func ParseCueErrors(dir, source, output string) diag.Diagnostics {
+	diags := diag.Diagnostics{}
+
+	var message *diag.Diagnostic
+	positions := 0
+
+	flush := func() {
+		if message != nil && positions == 0 {
+			diags = append(diags, message)
+		}
+		message, positions = nil, 0
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if match := cuePositionPattern.FindStringSubmatch(line); match != nil && message != nil {
+			positioned := *message
+			positioned.File = filepath.ToSlash(filepath.Clean(match[1]))
+			positioned.Line, _ = strconv.Atoi(match[2])
+			positioned.Column, _ = strconv.Atoi(match[3])
+			positioned.Snippet = sourceLine(dir, positioned.File, positioned.Line)
+			diags = append(diags, &positioned)
+			positions++
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if message != nil {
+				message.Message += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		flush()
+
+		if match := cueInlinePattern.FindStringSubmatch(line); match != nil {
+			d := &diag.Diagnostic{Severity: diag.SeverityError, Source: source}
+			d.File = filepath.ToSlash(filepath.Clean(match[1]))
+			d.Line, _ = strconv.Atoi(match[2])
+			d.Column, _ = strconv.Atoi(match[3])
+			d.Message = match[4]
+			d.Snippet = sourceLine(dir, d.File, d.Line)
+			diags = append(diags, d)
+			continue
+		}
+
+		message = &diag.Diagnostic{
+			Severity: diag.SeverityError,
+			Source:   source,
+			Message:  strings.TrimSuffix(line, ":"),
+		}
+		if match := cuePathPattern.FindStringSubmatch(message.Message); match != nil {
+			message.Path, message.Message = match[1], match[2]
+		}
+	}
+
+	flush()
+
+	return diags
+}
+
+// sourceLine reads one line of file, relative to dir unless it is absolute,
+// or nothing if it can't.
+func sourceLine(dir, file string, line int) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if n == line {
+			return scanner.Text()
+		}
+	}
+
+	return ""
+}
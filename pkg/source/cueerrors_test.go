@@ -0,0 +1,60 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCueErrors(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	r.Nil(os.MkdirAll(filepath.Join(dir, "jobs"), 0755))
+	r.Nil(os.WriteFile(filepath.Join(dir, "jobs", "web.cue"), []byte("package bitte\n\nweb: {\n\tdatacenters: 1\n}\n"), 0644))
+
+	diags := ParseCueErrors(dir, "cue vet", `rendered.prod.web.Job.Datacenters: conflicting values "eu" and 1 (mismatched types string and int):
+    ./jobs/web.cue:4:15
+    ./schema.cue:3:2
+some instances are incomplete; use the -c flag to show errors or suppress this message
+jobs/web.cue:6:1: expected '}', found 'EOF'
+`)
+
+	r.Equal(diag.Diagnostics{
+		{
+			Severity: diag.SeverityError,
+			Source:   "cue vet",
+			Path:     "rendered.prod.web.Job.Datacenters",
+			Message:  `conflicting values "eu" and 1 (mismatched types string and int)`,
+			File:     "jobs/web.cue",
+			Line:     4,
+			Column:   15,
+			Snippet:  "\tdatacenters: 1",
+		},
+		{
+			Severity: diag.SeverityError,
+			Source:   "cue vet",
+			Path:     "rendered.prod.web.Job.Datacenters",
+			Message:  `conflicting values "eu" and 1 (mismatched types string and int)`,
+			File:     "schema.cue",
+			Line:     3,
+			Column:   2,
+		},
+		{
+			Severity: diag.SeverityError,
+			Source:   "cue vet",
+			Message:  "some instances are incomplete; use the -c flag to show errors or suppress this message",
+		},
+		{
+			Severity: diag.SeverityError,
+			Source:   "cue vet",
+			Message:  "expected '}', found 'EOF'",
+			File:     "jobs/web.cue",
+			Line:     6,
+			Column:   1,
+		},
+	}, diags)
+}
@@ -0,0 +1,152 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+// SavedExport is a CUE export saved to a file, so jobs can be rendered where
+// cue isn't installed. Like any export, ReadJobs reads it as well.
+type SavedExport struct {
+	// Version of iogo that saved it.
+	Version string
+	// Inputs is the HashInputs of the CUE sources it was exported from.
+	Inputs string
+	// Secrets are the fields marked @secret() in the CUE sources.
+	Secrets []string `json:",omitempty"`
+	CueExport
+}
+
+// SaveExport vets and exports all jobs of c, for ExportFile to read later.
+func SaveExport(ctx context.Context, c *Cue, version string) (*SavedExport, diag.Diagnostics, error) {
+	dir := c.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	export, diags, err := c.Export(ctx)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	inputs, err := HashInputs(dir)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	secrets, err := SecretFields(dir)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	return &SavedExport{Version: version, Inputs: inputs, Secrets: secrets, CueExport: *export}, diags, nil
+}
+
+// ExportFile loads jobs from a SavedExport, warning if it is stale.
+type ExportFile struct {
+	Path string
+	// Dir has the CUE sources the export should match, it isn't compared
+	// if there are none.
+	Dir string
+	// Version of iogo that reads it, not compared if empty.
+	Version string
+
+	once  sync.Once
+	saved *SavedExport
+	diags diag.Diagnostics
+	err   error
+}
+
+// Saved reads the file, only once.
+func (f *ExportFile) Saved() (*SavedExport, diag.Diagnostics, error) {
+	f.once.Do(func() {
+		f.saved, f.diags, f.err = f.read()
+	})
+
+	return f.saved, f.diags, f.err
+}
+
+func (f *ExportFile) read() (*SavedExport, diag.Diagnostics, error) {
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	saved := &SavedExport{}
+	if err := json.Unmarshal(content, saved); err != nil {
+		return nil, nil, fmt.Errorf("Reading %s: %s", f.Path, err)
+	}
+
+	diags := diag.Diagnostics{}
+
+	if f.Version != "" && saved.Version != f.Version {
+		diags = append(diags, &diag.Diagnostic{
+			Severity: diag.SeverityInfo,
+			Source:   "export",
+			File:     f.Path,
+			Message:  fmt.Sprintf("saved by iogo %s, this is %s", saved.Version, f.Version),
+		})
+	}
+
+	if f.Dir != "" {
+		files, err := Inputs(f.Dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(files) > 0 {
+			inputs, err := HashInputs(f.Dir)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if inputs != saved.Inputs {
+				diags = append(diags, &diag.Diagnostic{
+					Severity: diag.SeverityWarning,
+					Source:   "export",
+					File:     f.Path,
+					Message:  fmt.Sprintf("stale, it was exported from CUE inputs %s but they are %s now", saved.Inputs, inputs),
+				})
+			}
+		}
+	}
+
+	return saved, diags, nil
+}
+
+// Namespaces lists the namespaces of the export.
+func (f *ExportFile) Namespaces(ctx context.Context) ([]string, diag.Diagnostics, error) {
+	saved, diags, err := f.Saved()
+	if err != nil {
+		return nil, diags, err
+	}
+
+	return saved.Namespaces(), diags, nil
+}
+
+// Jobs lists the jobs of the export.
+func (f *ExportFile) Jobs(ctx context.Context) ([]*NamespacedJob, diag.Diagnostics, error) {
+	saved, diags, err := f.Saved()
+	if err != nil {
+		return nil, diags, err
+	}
+
+	return saved.Jobs(), diags, nil
+}
+
+// Job looks up one job in the export.
+func (f *ExportFile) Job(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error) {
+	saved, diags, err := f.Saved()
+	if err != nil {
+		return nil, diags, err
+	}
+
+	found, err := saved.Job(namespace, job)
+	return found, diags, err
+}
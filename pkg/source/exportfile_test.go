@@ -0,0 +1,63 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFile(t *testing.T) {
+	r := require.New(t)
+
+	cue, _ := fakeCue(t, `{"prod": {"web": {"Job": {"Name": "web"}}}}`, false)
+	source := filepath.Join(cue.Dir, "web.cue")
+	r.Nil(os.WriteFile(source, []byte("TOKEN: string @secret()\n"), 0644))
+
+	saved, _, err := SaveExport(context.Background(), cue, "1.0")
+	r.Nil(err)
+	r.Equal("1.0", saved.Version)
+	r.Equal([]string{"TOKEN"}, saved.Secrets)
+
+	content, err := json.Marshal(saved)
+	r.Nil(err)
+	path := filepath.Join(t.TempDir(), "export.json")
+	r.Nil(os.WriteFile(path, content, 0644))
+
+	jobs, err := ReadJobs(content)
+	r.Nil(err)
+	r.Equal([]string{"prod/web"}, jobKeys(jobs))
+
+	file := &ExportFile{Path: path, Dir: cue.Dir, Version: "1.0"}
+	job, diags, err := file.Job(context.Background(), "prod", "web")
+	r.Nil(err)
+	r.Empty(diags)
+	r.Equal("web", *job.Name)
+
+	namespaces, _, err := file.Namespaces(context.Background())
+	r.Nil(err)
+	r.Equal([]string{"prod"}, namespaces)
+
+	_, _, err = file.Job(context.Background(), "prod", "nope")
+	r.EqualError(err, "Missing job nope in namespace prod")
+
+	r.Nil(os.WriteFile(source, []byte("TOKEN: string\n"), 0644))
+	file = &ExportFile{Path: path, Dir: cue.Dir, Version: "1.1"}
+	_, diags, err = file.Jobs(context.Background())
+	r.Nil(err)
+	r.Len(diags, 2)
+	r.Equal(diag.SeverityInfo, diags[0].Severity)
+	r.Equal("saved by iogo 1.0, this is 1.1", diags[0].Message)
+	r.Equal(diag.SeverityWarning, diags[1].Severity)
+	r.Contains(diags[1].Message, "stale, it was exported from CUE inputs "+saved.Inputs)
+
+	// without CUE sources around there's nothing to compare with
+	file = &ExportFile{Path: path, Dir: t.TempDir()}
+	_, diags, err = file.Jobs(context.Background())
+	r.Nil(err)
+	r.Empty(diags)
+}
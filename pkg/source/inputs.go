@@ -0,0 +1,170 @@
+package source
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Inputs lists the .cue files below dir and everything in cue.mod,
+// sorted by path.
+func Inputs(dir string) ([]string, error) {
+	files := []string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		inCueMod := strings.HasPrefix(filepath.ToSlash(rel), "cue.mod/")
+		if inCueMod || filepath.Ext(path) == ".cue" {
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	sort.Strings(files)
+
+	return files, err
+}
+
+// HashInputs hashes the path and content of every file from Inputs of the
+// CUE module dir is in, so changes to cue.mod or to packages imported from
+// elsewhere in the module change it as well.
+func HashInputs(dir string) (string, error) {
+	root, err := moduleRoot(dir)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := Inputs(root)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(file), len(content))
+		h.Write(content)
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// moduleRoot is the closest of dir and its parents that has a cue.mod, or
+// dir itself if none has.
+func moduleRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := abs; ; current = filepath.Dir(current) {
+		if info, err := os.Stat(filepath.Join(current, "cue.mod")); err == nil && info.IsDir() {
+			return current, nil
+		}
+
+		if filepath.Dir(current) == current {
+			return dir, nil
+		}
+	}
+}
+
+// cueTokenPattern matches identifiers and whole strings, the ones followed
+// by a colon are field labels like `DB_PASSWORD:` or `"api-key":`.
+var cueTokenPattern = regexp.MustCompile(`("(?:[^"\\]|\\.)*"|[A-Za-z0-9_\-]+)(\s*:)?`)
+
+// SecretFields lists the fields marked with a @secret() attribute in the
+// .cue files below dir, like `DB_PASSWORD: string @secret()`.
+func SecretFields(dir string) ([]string, error) {
+	found := map[string]bool{}
+
+	err := scanCue(dir, func(file string, line int, text string) {
+		i := strings.Index(text, "@secret(")
+		if i < 0 {
+			return
+		}
+
+		// the attribute belongs to the innermost field on the line
+		label := ""
+		for _, token := range cueTokenPattern.FindAllStringSubmatch(text[:i], -1) {
+			if token[2] != "" {
+				label = strings.Trim(token[1], `"`)
+			}
+		}
+
+		if label != "" {
+			found[label] = true
+		}
+	})
+
+	fields := []string{}
+	for field := range found {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields, err
+}
+
+// maxCueLine is the longest line scanCue reads.
+const maxCueLine = 16 * 1024 * 1024
+
+// scanCue calls fn with every line of the .cue files below dir.
+func scanCue(dir string, fn func(file string, line int, text string)) error {
+	files, err := Inputs(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if filepath.Ext(file) != ".cue" {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(f)
+		// embedded templates can make for very long lines
+		scanner.Buffer(nil, maxCueLine)
+		for line := 1; scanner.Scan(); line++ {
+			fn(file, line, scanner.Text())
+		}
+
+		f.Close()
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
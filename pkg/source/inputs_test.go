@@ -0,0 +1,121 @@
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeCueTree(r *require.Assertions, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "iogo")
+	r.Nil(err)
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		r.Nil(os.MkdirAll(filepath.Dir(path), 0755))
+		r.Nil(ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func TestHashInputs(t *testing.T) {
+	r := require.New(t)
+
+	dir := writeCueTree(r, map[string]string{
+		"cue.mod/module.cue": `module: "example.com/jobs"`,
+		"jobs/docs.cue":      `package jobs`,
+		"README.md":          "not an input",
+	})
+	defer os.RemoveAll(dir)
+
+	files, err := Inputs(dir)
+	r.Nil(err)
+	r.Equal([]string{"cue.mod/module.cue", "jobs/docs.cue"}, files)
+
+	before, err := HashInputs(dir)
+	r.Nil(err)
+	r.True(strings.HasPrefix(before, "sha256:"))
+
+	r.Nil(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("changed"), 0644))
+	unchanged, err := HashInputs(dir)
+	r.Nil(err)
+	r.Equal(before, unchanged)
+
+	r.Nil(ioutil.WriteFile(filepath.Join(dir, "jobs/docs.cue"), []byte("package docs"), 0644))
+	after, err := HashInputs(dir)
+	r.Nil(err)
+	r.NotEqual(before, after)
+}
+
+func TestHashInputsModuleRoot(t *testing.T) {
+	r := require.New(t)
+
+	dir := writeCueTree(r, map[string]string{
+		"cue.mod/module.cue": `module: "example.com/jobs"`,
+		"jobs/docs.cue":      `package jobs`,
+		"pkg/defaults.cue":   `package pkg`,
+	})
+	defer os.RemoveAll(dir)
+
+	jobs := filepath.Join(dir, "jobs")
+
+	root, err := moduleRoot(jobs)
+	r.Nil(err)
+	r.Equal(dir, root)
+
+	before, err := HashInputs(jobs)
+	r.Nil(err)
+
+	all, err := HashInputs(dir)
+	r.Nil(err)
+	r.Equal(all, before, "every package of the module counts")
+
+	r.Nil(ioutil.WriteFile(filepath.Join(dir, "pkg/defaults.cue"), []byte("package pkg // changed"), 0644))
+	imported, err := HashInputs(jobs)
+	r.Nil(err)
+	r.NotEqual(before, imported)
+
+	r.Nil(ioutil.WriteFile(filepath.Join(dir, "cue.mod/module.cue"), []byte(`module: "example.com/other"`), 0644))
+	module, err := HashInputs(jobs)
+	r.Nil(err)
+	r.NotEqual(imported, module)
+}
+
+func TestSecretFields(t *testing.T) {
+	r := require.New(t)
+
+	dir := writeCueTree(r, map[string]string{
+		"jobs/docs.cue": `package jobs
+
+env: {
+	DATABASE_URL: "postgres://docs:hunter2@db/docs" @secret()
+	"api-key":    string @secret()
+	PORT:         "8080"
+}
+task: server: env: SENTRY_DSN: string @secret()
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	fields, err := SecretFields(dir)
+	r.Nil(err)
+	r.Equal([]string{"DATABASE_URL", "SENTRY_DSN", "api-key"}, fields)
+}
+
+func TestSecretFieldsLongLines(t *testing.T) {
+	r := require.New(t)
+
+	dir := writeCueTree(r, map[string]string{
+		"jobs/docs.cue": "package jobs\n\ntemplate: data: \"" + strings.Repeat("x", 100*1024) + "\"\nTOKEN: string @secret()\n",
+	})
+	defer os.RemoveAll(dir)
+
+	fields, err := SecretFields(dir)
+	r.Nil(err)
+	r.Equal([]string{"TOKEN"}, fields)
+}
@@ -0,0 +1,172 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// NamespacedJob is a job together with the namespace and name it's filed
+// under, like the keys of CueExport.Rendered.
+type NamespacedJob struct {
+	Namespace string
+	Name      string
+	Job       *api.Job
+}
+
+// Path is where the job is written below dir, in a file with the given
+// extension.
+func (j *NamespacedJob) Path(dir, extension string) (string, error) {
+	for _, part := range []string{j.Namespace, j.Name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("Can't use %q as a file name for job %s in namespace %s", part, j.Name, j.Namespace)
+		}
+	}
+
+	return filepath.Join(dir, j.Namespace, j.Name+extension), nil
+}
+
+// ReadJobs reads every job from input, which is one of
+//   - a single {"Job": ...} document
+//   - a cue export with {"Rendered": {namespace: {job: {"Job": ...}}}}
+//   - an array of jobs, like /v1/jobs returns
+//   - a stream of jobs, one JSON document after the other
+//
+// Jobs in arrays and streams may be wrapped in {"Job": ...} or not.
+// Jobs are sorted by namespace and name.
+func ReadJobs(input []byte) ([]*NamespacedJob, error) {
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	jobs := []*NamespacedJob{}
+
+	for {
+		raw := json.RawMessage{}
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		found, err := readJobDocument(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, found...)
+	}
+
+	if len(jobs) == 0 {
+		return nil, errors.New("No jobs in input")
+	}
+
+	sortJobs(jobs)
+
+	return jobs, nil
+}
+
+func readJobDocument(raw json.RawMessage) ([]*NamespacedJob, error) {
+	trimmed := bytes.TrimSpace(raw)
+
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		elems := []json.RawMessage{}
+		if err := json.Unmarshal(trimmed, &elems); err != nil {
+			return nil, err
+		}
+
+		jobs := []*NamespacedJob{}
+		for i, elem := range elems {
+			job, err := readJob(elem)
+			if err != nil {
+				return nil, fmt.Errorf("Job %d: %s", i, err)
+			}
+			jobs = append(jobs, job)
+		}
+
+		return jobs, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := lookupField(fields, "Rendered"); ok {
+		export := &CueExport{}
+		if err := json.Unmarshal(trimmed, export); err != nil {
+			return nil, err
+		}
+
+		jobs := export.Jobs()
+		for _, job := range jobs {
+			if job.Job == nil {
+				return nil, fmt.Errorf("Missing Job of %s in namespace %s", job.Name, job.Namespace)
+			}
+		}
+
+		return jobs, nil
+	}
+
+	job, err := readJob(trimmed)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*NamespacedJob{job}, nil
+}
+
+// readJob reads a job that may or may not be wrapped in {"Job": ...}. Its
+// namespace defaults to "default" and its name to its ID.
+func readJob(raw json.RawMessage) (*NamespacedJob, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	job := &api.Job{}
+
+	if wrapped, ok := lookupField(fields, "Job"); ok {
+		raw = wrapped
+	}
+
+	if err := json.Unmarshal(raw, job); err != nil {
+		return nil, err
+	}
+
+	found := &NamespacedJob{Namespace: "default", Job: job}
+
+	if job.Namespace != nil && *job.Namespace != "" {
+		found.Namespace = *job.Namespace
+	}
+
+	switch {
+	case job.Name != nil && *job.Name != "":
+		found.Name = *job.Name
+	case job.ID != nil && *job.ID != "":
+		found.Name = *job.ID
+	default:
+		return nil, errors.New("No job in document, it has neither Job, Name nor ID")
+	}
+
+	return found, nil
+}
+
+// lookupField finds a field ignoring case, the way encoding/json matches
+// struct fields, so a cue export with "rendered" is read like "Rendered".
+func lookupField(fields map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+
+	for key, value := range fields {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func jobKeys(jobs []*NamespacedJob) []string {
+	keys := []string{}
+	for _, job := range jobs {
+		keys = append(keys, job.Namespace+"/"+job.Name)
+	}
+	return keys
+}
+
+func TestReadJobs(t *testing.T) {
+	r := require.New(t)
+
+	jobs, err := ReadJobs([]byte(`{"Job": {"Name": "docs"}}`))
+	r.Nil(err)
+	r.Equal([]string{"default/docs"}, jobKeys(jobs))
+
+	jobs, err = ReadJobs([]byte(`{"Rendered": {
+		"prod": {"web": {"Job": {"Name": "web"}}, "api": {"Job": {"Name": "api"}}},
+		"infra": {"docs": {"Job": {"Name": "docs"}}}
+	}}`))
+	r.Nil(err)
+	r.Equal([]string{"infra/docs", "prod/api", "prod/web"}, jobKeys(jobs))
+	r.Equal("api", *jobs[1].Job.Name)
+
+	jobs, err = ReadJobs([]byte(`[
+		{"ID": "web", "Namespace": "prod"},
+		{"Job": {"Name": "docs", "Namespace": "infra"}}
+	]`))
+	r.Nil(err)
+	r.Equal([]string{"infra/docs", "prod/web"}, jobKeys(jobs))
+
+	jobs, err = ReadJobs([]byte(`{"Job": {"Name": "web"}}
+{"Name": "api", "Namespace": "prod"}
+`))
+	r.Nil(err)
+	r.Equal([]string{"default/web", "prod/api"}, jobKeys(jobs))
+
+	// cue export writes the field as it is named in CUE
+	jobs, err = ReadJobs([]byte(`{"rendered": {"prod": {"web": {"Job": {"Name": "web"}}}}}`))
+	r.Nil(err)
+	r.Equal([]string{"prod/web"}, jobKeys(jobs))
+
+	jobs, err = ReadJobs([]byte(`{"job": {"ID": "web"}}`))
+	r.Nil(err)
+	r.Equal([]string{"default/web"}, jobKeys(jobs))
+
+	_, err = ReadJobs([]byte(`{"rendered": {"prod": {"web": {}}}}`))
+	r.EqualError(err, "Missing Job of web in namespace prod")
+
+	_, err = ReadJobs([]byte(`{"Datacenters": ["eu"]}`))
+	r.EqualError(err, "No job in document, it has neither Job, Name nor ID")
+
+	_, err = ReadJobs([]byte(` `))
+	r.EqualError(err, "No jobs in input")
+
+	_, err = ReadJobs([]byte(`[{"Name": "web"}, "web"]`))
+	r.Error(err)
+	r.Contains(err.Error(), "Job 1: json: cannot unmarshal string")
+}
+
+func TestNamespacedJobPath(t *testing.T) {
+	r := require.New(t)
+
+	path, err := (&NamespacedJob{Namespace: "prod", Name: "web"}).Path("jobs", ".hcl")
+	r.Nil(err)
+	r.Equal(filepath.Join("jobs", "prod", "web.hcl"), path)
+
+	_, err = (&NamespacedJob{Namespace: "..", Name: "web"}).Path("jobs", ".hcl")
+	r.EqualError(err, `Can't use ".." as a file name for job web in namespace ..`)
+}
@@ -0,0 +1,91 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+// JsonDir loads jobs from Dir/<namespace>/<job>.json, each like
+// {"Job": ...}, the way json2hcl --output-dir --format json writes them.
+type JsonDir struct {
+	Dir string
+}
+
+// Namespaces lists the directories below Dir.
+func (d *JsonDir) Namespaces(ctx context.Context) ([]string, diag.Diagnostics, error) {
+	entries, err := ioutil.ReadDir(d.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaces := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			namespaces = append(namespaces, entry.Name())
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil, nil
+}
+
+// Jobs lists the .json files in the namespace directories, without reading
+// them.
+func (d *JsonDir) Jobs(ctx context.Context) ([]*NamespacedJob, diag.Diagnostics, error) {
+	namespaces, _, err := d.Namespaces(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jobs := []*NamespacedJob{}
+	for _, namespace := range namespaces {
+		entries, err := ioutil.ReadDir(filepath.Join(d.Dir, namespace))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+				jobs = append(jobs, &NamespacedJob{Namespace: namespace, Name: strings.TrimSuffix(entry.Name(), ".json")})
+			}
+		}
+	}
+	sortJobs(jobs)
+
+	return jobs, nil, nil
+}
+
+// Job reads Dir/<namespace>/<job>.json.
+func (d *JsonDir) Job(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error) {
+	path, err := (&NamespacedJob{Namespace: namespace, Name: job}).Path(d.Dir, ".json")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	jobs, err := ReadJobs(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Reading %s: %s", path, err)
+	}
+
+	if len(jobs) != 1 {
+		return nil, nil, fmt.Errorf("%s has %d jobs instead of one", path, len(jobs))
+	}
+
+	return jobs[0].Job, nil, nil
+}
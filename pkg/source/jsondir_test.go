@@ -0,0 +1,48 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonDir(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"prod/web.json":  `{"Job": {"Name": "web"}}`,
+		"prod/api.json":  `{"ID": "api"}`,
+		"prod/README.md": `not a job`,
+		"infra/two.json": `[{"ID": "a"}, {"ID": "b"}]`,
+	} {
+		r.Nil(os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		r.Nil(os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+
+	source := &JsonDir{Dir: dir}
+
+	namespaces, _, err := source.Namespaces(context.Background())
+	r.Nil(err)
+	r.Equal([]string{"infra", "prod"}, namespaces)
+
+	jobs, _, err := source.Jobs(context.Background())
+	r.Nil(err)
+	r.Equal([]string{"infra/two", "prod/api", "prod/web"}, jobKeys(jobs))
+
+	job, _, err := source.Job(context.Background(), "prod", "api")
+	r.Nil(err)
+	r.Equal("api", *job.ID)
+
+	_, _, err = source.Job(context.Background(), "prod", "nope")
+	r.EqualError(err, "Missing job nope in namespace prod")
+
+	_, _, err = source.Job(context.Background(), "infra", "two")
+	r.EqualError(err, filepath.Join(dir, "infra", "two.json")+" has 2 jobs instead of one")
+
+	_, _, err = source.Job(context.Background(), "..", "web")
+	r.NotNil(err)
+}
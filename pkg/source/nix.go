@@ -0,0 +1,174 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+// Nix loads jobs by evaluating a Nix attribute with nix eval --json, the
+// way older bitte deployments define them.
+type Nix struct {
+	// Dir is where nix runs, the working directory if empty.
+	Dir string
+	// Command is the nix binary, nix from the PATH if empty.
+	Command string
+	// Attribute holds the jobs by namespace and name, each like
+	// {"Job": ...}, for example .#nomadJobs.
+	Attribute string
+}
+
+// NixError is a nix command that failed, along with what it printed.
+type NixError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *NixError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("%s failed: %s", e.Command, e.Err)
+	}
+
+	return fmt.Sprintf("%s failed: %s\n%s", e.Command, e.Err, e.Output)
+}
+
+func (e *NixError) Unwrap() error {
+	return e.Err
+}
+
+// eval evaluates attribute, passing the result through apply if given.
+// Whatever nix prints besides the result, like warnings about a dirty git
+// tree, is returned as info.
+func (n *Nix) eval(ctx context.Context, attribute, apply string, result interface{}) (diag.Diagnostics, error) {
+	command := n.Command
+	if command == "" {
+		command = "nix"
+	}
+
+	args := []string{"eval", "--json", attribute}
+	if apply != "" {
+		args = append(args, "--apply", apply)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = n.Dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, &NixError{
+			Command: strings.Join(append([]string{command}, args...), " "),
+			Output:  strings.TrimSpace(stderr.String()),
+			Err:     err,
+		}
+	}
+
+	var diags diag.Diagnostics
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		diags = diag.Diagnostics{{Severity: diag.SeverityInfo, Source: "nix eval", Message: message}}
+	}
+
+	return diags, json.Unmarshal(stdout.Bytes(), result)
+}
+
+func (n *Nix) attribute() (string, error) {
+	if n.Attribute == "" {
+		return "", fmt.Errorf("Missing the Nix attribute with the jobs, like .#nomadJobs")
+	}
+
+	return n.Attribute, nil
+}
+
+// Namespaces lists the attributes of Attribute, without evaluating jobs.
+func (n *Nix) Namespaces(ctx context.Context) ([]string, diag.Diagnostics, error) {
+	attribute, err := n.attribute()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaces := []string{}
+	diags, err := n.eval(ctx, attribute, "builtins.attrNames", &namespaces)
+	sort.Strings(namespaces)
+
+	return namespaces, diags, err
+}
+
+// Jobs lists the namespaces and names of the jobs, without evaluating them.
+func (n *Nix) Jobs(ctx context.Context) ([]*NamespacedJob, diag.Diagnostics, error) {
+	attribute, err := n.attribute()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := map[string][]string{}
+	diags, err := n.eval(ctx, attribute, "builtins.mapAttrs (namespace: builtins.attrNames)", &names)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	jobs := []*NamespacedJob{}
+	for namespace, namespaceJobs := range names {
+		for _, name := range namespaceJobs {
+			jobs = append(jobs, &NamespacedJob{Namespace: namespace, Name: name})
+		}
+	}
+	sortJobs(jobs)
+
+	return jobs, diags, nil
+}
+
+// Job evaluates only the given job.
+func (n *Nix) Job(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error) {
+	attribute, err := n.attribute()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapper := &JobWrapper{}
+	diags, err := n.eval(ctx, attribute+"."+nixString(namespace)+"."+nixString(job), "", wrapper)
+	if err != nil {
+		var nixErr *NixError
+		if errors.As(err, &nixErr) && isNixMissing(nixErr.Output, namespace, job) {
+			return nil, diags, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+		}
+		return nil, diags, err
+	}
+
+	if wrapper.Job == nil {
+		return nil, diags, fmt.Errorf("Missing job %s in namespace %s", job, namespace)
+	}
+
+	return wrapper.Job, diags, nil
+}
+
+// isNixMissing is true if nix failed because the namespace or job doesn't
+// exist, rather than an attribute inside the job.
+func isNixMissing(output, namespace, job string) bool {
+	if strings.Contains(output, "does not provide attribute") {
+		return true
+	}
+
+	for _, name := range []string{namespace, job} {
+		if strings.Contains(output, fmt.Sprintf("attribute '%s' missing", name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nixString quotes s as an attribute name.
+func nixString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return strings.ReplaceAll(string(quoted), "${", `\${`)
+}
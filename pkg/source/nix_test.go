@@ -0,0 +1,68 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNix writes a nix command that logs its arguments to calls, warns on
+// stderr and prints the output for the last argument from outputs.
+func fakeNix(t *testing.T, outputs map[string]string) (*Nix, string) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + calls + "\n" +
+		"echo 'warning: Git tree is dirty' >&2\n" +
+		"for last; do :; done\n" +
+		"case \"$last\" in\n"
+	for last, output := range outputs {
+		script += "'" + last + "') echo '" + output + "' ;;\n"
+	}
+	script += "*) echo \"error: attribute 'nope' missing\" >&2; exit 1 ;;\nesac\n"
+
+	command := filepath.Join(dir, "nix")
+	require.Nil(t, os.WriteFile(command, []byte(script), 0755))
+
+	return &Nix{Dir: dir, Command: command, Attribute: ".#nomadJobs"}, calls
+}
+
+func TestNix(t *testing.T) {
+	r := require.New(t)
+
+	nix, calls := fakeNix(t, map[string]string{
+		"builtins.attrNames": `["prod", "infra"]`,
+		"builtins.mapAttrs (namespace: builtins.attrNames)": `{"prod": ["web", "api"], "infra": ["docs"]}`,
+		`.#nomadJobs."prod"."web"`:                          `{"Job": {"Name": "web"}}`,
+	})
+
+	namespaces, diags, err := nix.Namespaces(context.Background())
+	r.Nil(err)
+	r.Equal([]string{"infra", "prod"}, namespaces)
+	r.Equal("info: nix eval: warning: Git tree is dirty", diags.Error())
+
+	jobs, _, err := nix.Jobs(context.Background())
+	r.Nil(err)
+	r.Equal([]string{"infra/docs", "prod/api", "prod/web"}, jobKeys(jobs))
+
+	job, _, err := nix.Job(context.Background(), "prod", "web")
+	r.Nil(err)
+	r.Equal("web", *job.Name)
+
+	_, _, err = nix.Job(context.Background(), "prod", "nope")
+	r.EqualError(err, "Missing job nope in namespace prod")
+
+	logged, err := os.ReadFile(calls)
+	r.Nil(err)
+	r.Equal([]string{
+		"eval --json .#nomadJobs --apply builtins.attrNames",
+		"eval --json .#nomadJobs --apply builtins.mapAttrs (namespace: builtins.attrNames)",
+		`eval --json .#nomadJobs."prod"."web"`,
+		`eval --json .#nomadJobs."prod"."nope"`,
+	}, strings.Split(strings.TrimSpace(string(logged)), "\n"))
+}
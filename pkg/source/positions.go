@@ -0,0 +1,98 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+)
+
+// Position asks cue where the struct at expression, like
+// rendered["prod"]["web"].Job.TaskGroups[0], is defined, as file:line. cue
+// only prints positions with errors, so the struct is unified with a number,
+// which conflicts with it and lists where it came from. It is empty if cue
+// lists no position outside of cue.mod, where shared schemas live.
+func (c *Cue) Position(ctx context.Context, expression string) (string, error) {
+	args := []string{"eval", "-e", fmt.Sprintf("(%s) & 0", expression)}
+	if c.Package != "" {
+		args = append(args, ".:"+c.Package)
+	}
+
+	_, err := c.run(ctx, c.tagArgs(args...)...)
+
+	var cueErr *CueError
+	if !errors.As(err, &cueErr) {
+		return "", err
+	}
+
+	conflict := false
+	for _, d := range cueErr.Diagnostics {
+		if !strings.HasPrefix(d.Message, "conflicting values") {
+			continue
+		}
+		conflict = true
+
+		if !strings.HasSuffix(d.File, ".cue") || strings.HasPrefix(d.File, "cue.mod/") {
+			continue
+		}
+
+		return fmt.Sprintf("%s:%d", d.File, d.Line), nil
+	}
+
+	// anything else is a real error, like a missing job
+	if !conflict {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// BlockPositions finds where each group and task of job is defined, keyed
+// by their path in the HCL, like job["web"].group["api"].task["server"].
+// Blocks cue has no position for are left out.
+func (c *Cue) BlockPositions(ctx context.Context, namespace, name string, job *api.Job) (map[string]string, error) {
+	positions := map[string]string{}
+
+	label := name
+	if job.Name != nil {
+		label = *job.Name
+	}
+
+	add := func(path, expression string) error {
+		position, err := c.Position(ctx, expression)
+		if err != nil || position == "" {
+			return err
+		}
+
+		positions[path] = position
+		return nil
+	}
+
+	for i, group := range job.TaskGroups {
+		if group == nil || group.Name == nil {
+			continue
+		}
+
+		groupPath := fmt.Sprintf("job[%q].group[%q]", label, *group.Name)
+		groupExpression := fmt.Sprintf("%s.Job.TaskGroups[%d]", c.jobExpression(namespace, name), i)
+
+		if err := add(groupPath, groupExpression); err != nil {
+			return nil, err
+		}
+
+		for j, task := range group.Tasks {
+			if task == nil {
+				continue
+			}
+
+			taskPath := fmt.Sprintf("%s.task[%q]", groupPath, task.Name)
+			if err := add(taskPath, fmt.Sprintf("%s.Tasks[%d]", groupExpression, j)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,76 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/require"
+)
+
+// conflictingCue writes a cue command that fails like cue eval does when a
+// struct is unified with a number, listing the positions for the first
+// expression in positions that the arguments contain.
+func conflictingCue(t *testing.T, positions [][2]string) (*Cue, string) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\ncase \"$*\" in\n"
+	for _, position := range positions {
+		script += "*'" + position[0] + "'*)\n" +
+			"  echo 'conflicting values 0 and {} (mismatched types int and struct):'\n" +
+			"  printf '" + position[1] + "\\n'\n" +
+			"  exit 1 ;;\n"
+	}
+	script += "esac\necho 'rendered.prod.nope: undefined field: nope'\nexit 1\n"
+
+	command := filepath.Join(dir, "cue")
+	require.Nil(t, os.WriteFile(command, []byte(script), 0755))
+
+	return &Cue{Dir: dir, Command: command}, calls
+}
+
+func TestBlockPositions(t *testing.T) {
+	r := require.New(t)
+
+	cue, calls := conflictingCue(t, [][2]string{
+		{"TaskGroups[0].Tasks[0]) & 0", `    ./jobs/docs.cue:5:19`},
+		{"TaskGroups[0].Tasks[1]) & 0", `    ./cue.mod/pkg/schema/task.cue:3:9`},
+		{"TaskGroups[0]) & 0", `    ./cue.mod/pkg/schema/group.cue:8:2\n    ./jobs/docs.cue:4:18`},
+		{"TaskGroups[1].Tasks[0]) & 0", `    ./jobs/docs.cue:12:10`},
+		{"TaskGroups[1]) & 0", `    ./jobs/docs.cue:11:10`},
+	})
+	cue.Package = "bitte"
+
+	// both groups have a task called server
+	positions, err := cue.BlockPositions(context.Background(), "prod", "docs", &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{
+			{Name: ptrStr("api"), Tasks: []*api.Task{{Name: "server"}, {Name: "generated"}}},
+			{Name: ptrStr("worker"), Tasks: []*api.Task{{Name: "server"}}},
+		},
+	})
+	r.Nil(err)
+	r.Equal(map[string]string{
+		`job["docs"].group["api"]`:                   "jobs/docs.cue:4",
+		`job["docs"].group["api"].task["server"]`:    "jobs/docs.cue:5",
+		`job["docs"].group["worker"]`:                "jobs/docs.cue:11",
+		`job["docs"].group["worker"].task["server"]`: "jobs/docs.cue:12",
+	}, positions)
+
+	logged, err := os.ReadFile(calls)
+	r.Nil(err)
+	r.Equal(`eval -e (rendered["prod"]["docs"].Job.TaskGroups[0]) & 0 .:bitte`, strings.Split(string(logged), "\n")[0])
+
+	// other errors aren't positions
+	_, err = cue.Position(context.Background(), `rendered["prod"]["nope"]`)
+	r.NotNil(err)
+	r.Contains(err.Error(), "undefined field")
+}
+
+func ptrStr(v string) *string {
+	return &v
+}
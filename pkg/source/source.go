@@ -0,0 +1,87 @@
+package source
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/diag"
+)
+
+// JobSource is where jobs are defined, like a CUE tree, a Nix flake or a
+// directory of JSON files.
+type JobSource interface {
+	// Namespaces lists the namespaces with jobs, sorted.
+	Namespaces(ctx context.Context) ([]string, diag.Diagnostics, error)
+	// Jobs lists every job sorted by namespace and name. Their Job may be
+	// nil, Job loads it.
+	Jobs(ctx context.Context) ([]*NamespacedJob, diag.Diagnostics, error)
+	// Job loads one job.
+	Job(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error)
+}
+
+var (
+	_ JobSource = &Cue{}
+	_ JobSource = &Nix{}
+	_ JobSource = &JsonDir{}
+	_ JobSource = &ExportFile{}
+)
+
+// Namespaces lists the namespaces of the export.
+func (c *Cue) Namespaces(ctx context.Context) ([]string, diag.Diagnostics, error) {
+	export, diags, err := c.Export(ctx)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	return export.Namespaces(), diags, nil
+}
+
+// Jobs lists the jobs of the export, it has to export all of them anyway.
+func (c *Cue) Jobs(ctx context.Context) ([]*NamespacedJob, diag.Diagnostics, error) {
+	export, diags, err := c.Export(ctx)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	return export.Jobs(), diags, nil
+}
+
+// Job exports only the given job, see ExportJob.
+func (c *Cue) Job(ctx context.Context, namespace, job string) (*api.Job, diag.Diagnostics, error) {
+	return c.ExportJob(ctx, namespace, job)
+}
+
+// Namespaces lists the namespaces of the export, sorted.
+func (e *CueExport) Namespaces() []string {
+	namespaces := []string{}
+	for namespace := range e.Rendered {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// Jobs lists the jobs of the export, sorted by namespace and name.
+func (e *CueExport) Jobs() []*NamespacedJob {
+	jobs := []*NamespacedJob{}
+	for namespace, namespaceJobs := range e.Rendered {
+		for name, wrapper := range namespaceJobs {
+			jobs = append(jobs, &NamespacedJob{Namespace: namespace, Name: name, Job: wrapper.Job})
+		}
+	}
+	sortJobs(jobs)
+
+	return jobs
+}
+
+// sortJobs sorts by namespace and name.
+func sortJobs(jobs []*NamespacedJob) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Namespace != jobs[j].Namespace {
+			return jobs[i].Namespace < jobs[j].Namespace
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+)
+
+// Provenance says where a rendered job came from, so a .hcl file found on
+// disk or in a plan can be traced back to its CUE sources.
+type Provenance struct {
+	Namespace string
+	Job       string
+	// Inputs is a hash of every CUE file the job was exported from, empty
+	// for sources other than CUE or a saved export.
+	Inputs string
+	Time   time.Time
+}
+
+func newProvenance(namespace, job string) (*Provenance, error) {
+	inputs, err := sourceInputs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provenance{
+		Namespace: namespace,
+		Job:       job,
+		Inputs:    inputs,
+		Time:      time.Now().UTC(),
+	}, nil
+}
+
+// Header is the comment written in front of the rendered job.
+func (p *Provenance) Header() string {
+	header := fmt.Sprintf("# Generated by iogo %s, do not edit.\n# Job: %s/%s\n", Version(), p.Namespace, p.Job)
+	if p.Inputs != "" {
+		header += fmt.Sprintf("# CUE inputs: %s\n", p.Inputs)
+	}
+
+	return header + fmt.Sprintf("# Rendered at: %s\n", p.Time.Format(time.RFC3339))
+}
+
+// writeHcl writes src to w, with the header of p in front of it if given.
+func writeHcl(w io.Writer, p *Provenance, src []byte) error {
+	if p != nil {
+		if _, err := io.WriteString(w, p.Header()+"\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(src)
+	return err
+}
+
+// cueAnnotations returns an hcl.Options.Annotate that names the CUE file and
+// line each group and task of job is defined at, as cue reports it.
+func cueAnnotations(c *source.Cue, namespace, name string, job *api.Job) (func(path string) string, error) {
+	positions, err := c.BlockPositions(context.Background(), namespace, name, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(path string) string {
+		if position, ok := positions[path]; ok {
+			return "defined at " + position
+		}
+		return ""
+	}, nil
+}
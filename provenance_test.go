@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/hcl"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenanceHeader(t *testing.T) {
+	r := require.New(t)
+
+	f := hclwrite.NewEmptyFile()
+	f.Body().AppendNewBlock("job", []string{"docs"})
+
+	out := &bytes.Buffer{}
+	r.Nil(writeHcl(out, &Provenance{
+		Namespace: "infra",
+		Job:       "docs",
+		Inputs:    "sha256:1234",
+		Time:      time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC),
+	}, f.Bytes()))
+
+	r.Equal(`# Generated by iogo dev (dirty), do not edit.
+# Job: infra/docs
+# CUE inputs: sha256:1234
+# Rendered at: 2021-09-04T12:00:00Z
+
+job "docs" {
+}
+`, out.String())
+}
+
+func TestCueAnnotations(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "iogo")
+	r.Nil(err)
+	defer os.RemoveAll(dir)
+
+	// a cue that only knows where the first group and its first task are
+	command := filepath.Join(dir, "cue")
+	r.Nil(ioutil.WriteFile(command, []byte(`#!/bin/sh
+echo 'conflicting values 0 and {} (mismatched types int and struct):'
+case "$*" in
+*'Tasks[0]) & 0'*) echo '    ./jobs/docs.cue:5:19' ;;
+*'TaskGroups[0]) & 0'*) echo '    ./jobs/docs.cue:4:18' ;;
+esac
+exit 1
+`), 0755))
+
+	job := &api.Job{
+		Name: ptrStr("docs"),
+		TaskGroups: []*api.TaskGroup{{
+			Name: ptrStr("example"),
+			Tasks: []*api.Task{
+				{Name: "server", Driver: "docker"},
+				{Name: "generated", Driver: "docker"},
+			},
+		}},
+	}
+
+	annotate, err := cueAnnotations(&source.Cue{Dir: dir, Command: command}, "prod", "docs", job)
+	r.Nil(err)
+
+	f, _, err := hcl.Render(job, hcl.Options{Annotate: annotate})
+	r.Nil(err)
+	r.Equal(`job "docs" {
+  # defined at jobs/docs.cue:4
+  group "example" {
+    # defined at jobs/docs.cue:5
+    task "server" {
+      driver = "docker"
+    }
+
+    task "generated" {
+      driver = "docker"
+    }
+  }
+}`, strings.TrimSpace(string(f.Bytes())))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/redact"
+)
+
+// redactor masks secrets in everything printed to a terminal, unless
+// --reveal is given. Files written with -o are left alone, they are for
+// nomad.
+var redactor = redact.New(redact.Rules{})
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWriter masks secrets on their way to f if it is a terminal.
+func terminalWriter(f *os.File) io.Writer {
+	if isTerminal(f) {
+		return redactor.Writer(f)
+	}
+
+	return f
+}
+
+// flush writes what a terminalWriter still holds back.
+func flush(w io.Writer) error {
+	if rw, ok := w.(*redact.Writer); ok {
+		return rw.Flush()
+	}
+
+	return nil
+}
+
+// redactOutput masks the secrets of job if output goes to a terminal.
+func redactOutput(output string, job *api.Job) (*api.Job, error) {
+	if isStdpipe(output) && isTerminal(os.Stdout) {
+		return redactor.Job(job)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+)
+
+// Sources of jobs for --source.
+const (
+	sourceCue  = "cue"
+	sourceNix  = "nix"
+	sourceJson = "json"
+)
+
+// jobSource is where every command gets its jobs, configured with --source.
+var jobSource source.JobSource
+
+// jobSourceFor picks the source from the flags or the project config, CUE
+// if neither names one.
+func jobSourceFor(args *iogo, config *projectConfig) (source.JobSource, error) {
+	if args.FromExport != "" {
+		return &source.ExportFile{Path: args.FromExport, Dir: cueDir(), Version: Version()}, nil
+	}
+
+	switch kind := firstOf(args.Source, config.Source, sourceCue); kind {
+	case sourceCue:
+		return cueSource(), nil
+	case sourceNix:
+		return &source.Nix{Attribute: firstOf(args.NixAttribute, config.Nix.Attribute)}, nil
+	case sourceJson:
+		dir := firstOf(args.JobsDir, config.Json.Dir)
+		if dir == "" {
+			return nil, fmt.Errorf("--source %s needs --jobs-dir", sourceJson)
+		}
+		return &source.JsonDir{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("Unknown source %q, use one of %s, %s or %s", kind, sourceCue, sourceNix, sourceJson)
+	}
+}
+
+// isCueSource is true if jobs come from CUE, which is the only source with
+// files to annotate.
+func isCueSource() bool {
+	_, ok := jobSource.(*source.Cue)
+	return ok
+}
+
+var (
+	secretsOnce  sync.Once
+	knownSecrets []string
+	secretsErr   error
+)
+
+// sourceSecrets are the fields marked @secret() in the CUE sources, or in
+// the sources of a saved export. The sources are only scanned once per run.
+func sourceSecrets() ([]string, error) {
+	secretsOnce.Do(func() {
+		knownSecrets, secretsErr = findSecrets()
+	})
+
+	return knownSecrets, secretsErr
+}
+
+func findSecrets() ([]string, error) {
+	switch s := jobSource.(type) {
+	case *source.Cue:
+		return source.SecretFields(cueDir())
+	case *source.ExportFile:
+		saved, _, err := s.Saved()
+		if err != nil {
+			return nil, err
+		}
+		return saved.Secrets, nil
+	}
+
+	return nil, nil
+}
+
+// sourceInputs is the hash of the CUE sources, or of those of a saved
+// export, empty for the other sources.
+func sourceInputs() (string, error) {
+	switch s := jobSource.(type) {
+	case *source.Cue:
+		return source.HashInputs(cueDir())
+	case *source.ExportFile:
+		saved, _, err := s.Saved()
+		if err != nil {
+			return "", err
+		}
+		return saved.Inputs, nil
+	}
+
+	return "", nil
+}
+
+// listJobs lists every job, passing whatever the source printed on to the
+// diagnostics, so it never ends up on stdout.
+func listJobs() ([]*source.NamespacedJob, error) {
+	jobs, diags, err := jobSource.Jobs(context.Background())
+	if err := diagnostics.Write(diags); err != nil {
+		return nil, err
+	}
+
+	return jobs, cueFailed(err)
+}
+
+func listNamespaces() ([]string, error) {
+	namespaces, diags, err := jobSource.Namespaces(context.Background())
+	if err := diagnostics.Write(diags); err != nil {
+		return nil, err
+	}
+
+	return namespaces, cueFailed(err)
+}
+
+// loadJob loads only the given job. The fields marked @secret() in the CUE
+// sources are masked whenever it is printed.
+func loadJob(namespace, job string) (*api.Job, error) {
+	found, diags, err := jobSource.Job(context.Background(), namespace, job)
+	if err := diagnostics.Write(diags); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, cueFailed(err)
+	}
+
+	secrets, err := sourceSecrets()
+	if err != nil {
+		return nil, err
+	}
+	redactor.Deny(secrets...)
+
+	return found, redactor.Learn(found)
+}
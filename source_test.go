@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/input-output-hk/bitte-iogo/pkg/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobSourceFor(t *testing.T) {
+	r := require.New(t)
+
+	found, err := jobSourceFor(&iogo{}, &projectConfig{})
+	r.Nil(err)
+	r.IsType(&source.Cue{}, found)
+
+	found, err = jobSourceFor(&iogo{}, &projectConfig{Source: sourceNix, Nix: nixConfig{Attribute: ".#nomadJobs"}})
+	r.Nil(err)
+	r.Equal(&source.Nix{Attribute: ".#nomadJobs"}, found)
+
+	found, err = jobSourceFor(&iogo{Source: sourceJson, JobsDir: "jobs"}, &projectConfig{Source: sourceNix})
+	r.Nil(err)
+	r.Equal(&source.JsonDir{Dir: "jobs"}, found)
+
+	found, err = jobSourceFor(&iogo{FromExport: "export.json"}, &projectConfig{Source: sourceNix})
+	r.Nil(err)
+	r.Equal("export.json", found.(*source.ExportFile).Path)
+
+	_, err = jobSourceFor(&iogo{Source: sourceJson}, &projectConfig{})
+	r.EqualError(err, "--source json needs --jobs-dir")
+
+	_, err = jobSourceFor(&iogo{Source: "yaml"}, &projectConfig{})
+	r.EqualError(err, `Unknown source "yaml", use one of cue, nix or json`)
+}